@@ -0,0 +1,49 @@
+// signer reference remote signing service
+// it loads one keystore file and serves sdk.NewSignerHandler, so the private key
+// only lives in this process and other services use sdk.NewRemoteSigner.
+//
+//	signer -keystore ./UTC--... -password ./password.txt -listen 127.0.0.1:8550 -token secret
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/bolaxytools/tool-sdk"
+)
+
+func main() {
+	var (
+		keystore = flag.String("keystore", "", "keystore file of the signing account")
+		password = flag.String("password", "", "file containing the keystore passphrase")
+		listen   = flag.String("listen", "127.0.0.1:8550", "listen address")
+		token    = flag.String("token", "", "bearer token required by clients, empty to disable")
+		chainID  = flag.Int64("chainid", 0, "chain id used to sign transactions, 0 for default")
+	)
+	flag.Parse()
+
+	keyjson, err := ioutil.ReadFile(*keystore)
+	if err != nil {
+		log.Fatalf("read keystore: %v", err)
+	}
+
+	passphrase, err := ioutil.ReadFile(*password)
+	if err != nil {
+		log.Fatalf("read password: %v", err)
+	}
+
+	key, err := sdk.DecryptKeyJSON(keyjson, strings.TrimRight(string(passphrase), "\r\n"))
+	if err != nil {
+		log.Fatalf("decrypt keystore: %v", err)
+	}
+	if *chainID > 0 {
+		key.SetChainID(big.NewInt(*chainID))
+	}
+
+	log.Printf("signer %s listening on %s\n", key.GetStringAddress(), *listen)
+	log.Fatal(http.ListenAndServe(*listen, sdk.NewSignerHandler(key, *token)))
+}
@@ -3,6 +3,8 @@ package sdk
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math/big"
 
 	"github.com/bolaxy/common"
 	"github.com/bolaxy/core/types"
@@ -11,6 +13,9 @@ import (
 	"github.com/bolaxy/rlp"
 )
 
+// ErrChainIDMismatch 交易签名的链ID与期望的链ID不一致
+var ErrChainIDMismatch = errors.New("chain id mismatch")
+
 // Transaction the display transaction
 type Transaction struct {
 	Hash     string // Hash hex hash string e.g 0xe349b239e5b2fbb8ebe96556c3caa4c2b419f9a51af5e497bba0735c88a48b6d
@@ -48,6 +53,11 @@ type Transaction struct {
 // 返回结果
 // 	[]*Transaction 本SDK下的交易结构。将信息内容解析成可读格式。
 func GetTransactions(serialized string) ([]*Transaction, map[string]string, error) {
+	return GetTransactionsWithChainID(serialized, common.ChainID)
+}
+
+// GetTransactionsWithChainID 同 GetTransactions，使用指定链ID校验交易签名
+func GetTransactionsWithChainID(serialized string, chainID *big.Int) ([]*Transaction, map[string]string, error) {
 	if len(serialized) == 0 {
 		return nil, nil, errors.New("wrong input param")
 	}
@@ -57,35 +67,9 @@ func GetTransactions(serialized string) ([]*Transaction, map[string]string, erro
 		return nil, nil, err
 	}
 
-	trans := block.Body.Transactions
-	transactions := make([]*Transaction, 0, len(trans))
-	for _, tran := range trans {
-		var t ethType.Transaction
-		if err := rlp.DecodeBytes(tran, &t); err != nil {
-			return nil, nil, err
-		}
-
-		from, err := ethType.Sender(ethType.NewEIP155Signer(common.ChainID), &t)
-		if err != nil {
-			return nil, nil, err
-		}
-
-		to := ""
-		if t.To() != nil {
-			to = t.To().String()
-		}
-
-		data := &Transaction{
-			From:     from.String(),
-			To:       to,
-			Value:    t.Value().String(),
-			Gas:      t.Gas(),
-			GasPrice: t.GasPrice().String(),
-			Data:     t.Data(),
-			Hash:     t.Hash().String(),
-		}
-
-		transactions = append(transactions, data)
+	transactions, err := decodeTransactions(block.Body.Transactions, chainID)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	sigs := make(map[string]string, len(block.GetSignatures()))
@@ -98,14 +82,28 @@ func GetTransactions(serialized string) ([]*Transaction, map[string]string, erro
 }
 
 func GetTransactionsFromBlk(blk *types.Block) ([]*Transaction, error) {
-	transactions := make([]*Transaction, 0, len(blk.Transactions()))
-	for _, tran := range blk.Transactions() {
+	return GetTransactionsFromBlkWithChainID(blk, common.ChainID)
+}
+
+// GetTransactionsFromBlkWithChainID 同 GetTransactionsFromBlk，使用指定链ID校验交易签名
+func GetTransactionsFromBlkWithChainID(blk *types.Block, chainID *big.Int) ([]*Transaction, error) {
+	return decodeTransactions(blk.Transactions(), chainID)
+}
+
+func decodeTransactions(trans [][]byte, chainID *big.Int) ([]*Transaction, error) {
+	signer := ethType.NewEIP155Signer(chainID)
+	transactions := make([]*Transaction, 0, len(trans))
+	for _, tran := range trans {
 		var t ethType.Transaction
 		if err := rlp.DecodeBytes(tran, &t); err != nil {
 			return nil, err
 		}
 
-		from, err := ethType.Sender(ethType.NewEIP155Signer(common.ChainID), &t)
+		if t.Protected() && t.ChainId().Cmp(chainID) != 0 {
+			return nil, fmt.Errorf("%w: tx %s has chain id %v, want %v", ErrChainIDMismatch, t.Hash().String(), t.ChainId(), chainID)
+		}
+
+		from, err := ethType.Sender(signer, &t)
 		if err != nil {
 			return nil, err
 		}
@@ -128,4 +126,4 @@ func GetTransactionsFromBlk(blk *types.Block) ([]*Transaction, error) {
 		transactions = append(transactions, data)
 	}
 	return transactions, nil
-}
\ No newline at end of file
+}
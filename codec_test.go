@@ -0,0 +1,45 @@
+package sdk
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/core/types"
+	ethType "github.com/bolaxy/eth/types"
+	"github.com/bolaxy/rlp"
+)
+
+func TestGetTransactionsFromBlkWithChainID(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key.SetChainID(big.NewInt(5))
+
+	tx := ethType.NewTransaction(1, common.Address{0x01}, big.NewInt(10), 21000, big.NewInt(1), nil)
+	signed, err := key.SignTx(tx)
+	if err != nil {
+		t.Fatalf("SignTx: %v", err)
+	}
+
+	raw, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		t.Fatalf("EncodeToBytes: %v", err)
+	}
+
+	blk := &types.Block{Body: types.BlockBody{Transactions: [][]byte{raw}}}
+	txs, err := GetTransactionsFromBlkWithChainID(blk, big.NewInt(5))
+	if err != nil {
+		t.Fatalf("GetTransactionsFromBlkWithChainID: %v", err)
+	}
+
+	if len(txs) != 1 || txs[0].From != key.GetStringAddress() || txs[0].Hash != signed.Hash().String() {
+		t.Fatalf("unexpected transactions: %+v", txs)
+	}
+
+	if _, err := GetTransactionsFromBlk(blk); !errors.Is(err, ErrChainIDMismatch) {
+		t.Fatalf("GetTransactionsFromBlk: have %v, want %v", err, ErrChainIDMismatch)
+	}
+}
@@ -2,10 +2,13 @@ package sdk
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/bolaxy/common"
 	"github.com/bolaxy/common/hexutil"
 	"github.com/bolaxy/crypto"
+
+	"github.com/bolaxytools/tool-sdk/metrics"
 )
 
 type Type string
@@ -18,12 +21,23 @@ const (
 	fireAlways
 )
 
+// Result 区块监控器发出的交易事件和日志事件的内容
 type Result struct {
 	Success         bool
 	ContractAddress *common.Address
 	IsLog           bool
 	Data            []byte
 	Topics          []common.Hash
+
+	BlockIndex    uint64          // BlockIndex 交易所在区块的索引
+	RoundReceived uint64          // RoundReceived 区块的 round received
+	TxHash        common.Hash     // TxHash 交易哈希
+	From          common.Address  // From 交易发送方
+	To            *common.Address // To 交易接收方，创建合约时为 nil
+	Value         *big.Int        // Value 转账金额，无法解析时为 nil
+	GasUsed       uint64          // GasUsed 交易消耗的 gas
+	LogIndex      uint            // LogIndex 日志在区块中的索引，仅日志事件有效
+	LogAddress    common.Address  // LogAddress 产生日志的合约地址，仅日志事件有效
 }
 
 type Event struct {
@@ -35,6 +49,11 @@ func GenLogType(contractAddr common.Address, eventSig common.Hash) Type {
 	return Type(hexutil.Encode(crypto.Keccak256(contractAddr.Bytes(), eventSig.Bytes())))
 }
 
+// GenAnonymousLogType 没有 topic 的匿名日志的事件类型，等同于 GenLogType(contractAddr, common.Hash{})
+func GenAnonymousLogType(contractAddr common.Address) Type {
+	return GenLogType(contractAddr, common.Hash{})
+}
+
 func GenHashType(txhash common.Hash) Type {
 	return Type(txhash.String())
 }
@@ -54,7 +73,17 @@ func (e *Event) GetType() Type {
 	return e.eventType
 }
 
-func NewEventEmitter(bufSize int) *Emitter {
+// EmitterOpt Emitter 的可选配置
+type EmitterOpt func(ee *Emitter)
+
+// WithEmitterMetrics 设置 Emitter 的监控指标：订阅数、队列深度、事件数和丢弃的事件数
+func WithEmitterMetrics(m metrics.Metrics) EmitterOpt {
+	return func(ee *Emitter) {
+		ee.metrics = m
+	}
+}
+
+func NewEventEmitter(bufSize int, opts ...EmitterOpt) *Emitter {
 	ee := &Emitter{
 		bufSize:      bufSize,
 		events:       make(map[uint64]map[Type]Kind),
@@ -63,6 +92,17 @@ func NewEventEmitter(bufSize int) *Emitter {
 		notify:       make(chan *Event), // 如果设置了buf，在cancel阶段会出现竞争问题，然后回调函数中会多次调用cancel
 		cancellation: make(chan uint64),
 	}
+	for _, opt := range opts {
+		opt(ee)
+	}
+
+	m := metrics.Or(ee.metrics)
+	ee.stats = &emitterStats{
+		subscribers: m.Gauge("bolaxy_emitter_subscribers", "Number of active subscriptions of the emitter.", nil),
+		queueDepth:  m.Gauge("bolaxy_emitter_queue_depth", "Number of events waiting in subscriber queues.", nil),
+		emitted:     m.Counter("bolaxy_emitter_events_total", "Total number of emitted events.", nil),
+		dropped:     m.Counter("bolaxy_emitter_dropped_events_total", "Total number of events dropped because a subscriber queue is full.", nil),
+	}
 
 	go ee.run()
 	return ee
@@ -76,6 +116,15 @@ type Emitter struct {
 	observer     chan *observer
 	notify       chan *Event
 	cancellation chan uint64
+	metrics      metrics.Metrics
+	stats        *emitterStats
+}
+
+type emitterStats struct {
+	subscribers metrics.Gauge
+	queueDepth  metrics.Gauge
+	emitted     metrics.Counter
+	dropped     metrics.Counter
 }
 
 func (ee *Emitter) run() {
@@ -98,13 +147,19 @@ func (ee *Emitter) run() {
 					cb = make(map[uint64]chan<- *Event)
 					ee.subscriber[et] = cb
 				}
+				if _, ok := cb[ee.counter]; ok {
+					// 重复的事件类型只订阅一次
+					continue
+				}
 
 				input := make(chan *Event)
-				newObservable(ee.counter, input, ee.bufSize, observer.fn)
+				newObservable(ee.counter, input, ee.bufSize, observer.fn, ee.stats)
 				cb[ee.counter] = input
+				ee.stats.subscribers.Add(1)
 			}
 			observer.signal <- ee.counter
 		case e := <-ee.notify:
+			ee.stats.emitted.Inc()
 			if item, ok := ee.subscriber[e.GetType()]; ok {
 				for id, ch := range item {
 					fmt.Printf("%s -> %d\n", e.GetType(), id)
@@ -112,20 +167,30 @@ func (ee *Emitter) run() {
 					ch <- e
 					if kind == fireOnce {
 						fmt.Printf("fireonce ...\n")
-						delete(ee.events, id)
-						delete(ee.subscriber[e.GetType()], id)
-						close(ch)
+						// 只触发一次的订阅在所有事件类型上都移除
+						ee.remove(id)
 					}
 				}
 			}
 		case id := <-ee.cancellation:
-			if items, ok := ee.events[id]; ok {
-				delete(ee.events, id)
-				for et := range items {
-					close(ee.subscriber[et][id])
-					delete(ee.subscriber[et], id)
-				}
-			}
+			ee.remove(id)
+		}
+	}
+}
+
+// remove 移除订阅 id 在每个事件类型上的订阅，每移除一个减少一次订阅数
+func (ee *Emitter) remove(id uint64) {
+	items, ok := ee.events[id]
+	if !ok {
+		return
+	}
+
+	delete(ee.events, id)
+	for et := range items {
+		if ch, ok := ee.subscriber[et][id]; ok {
+			close(ch)
+			delete(ee.subscriber[et], id)
+			ee.stats.subscribers.Add(-1)
 		}
 	}
 }
@@ -172,10 +237,11 @@ type observable struct {
 	output chan *Event
 	fn     Callback
 	index  uint64
+	stats  *emitterStats
 }
 
 // output must buffered channel
-func newObservable(index uint64, input <-chan *Event, bufSize int, fn Callback) *observable {
+func newObservable(index uint64, input <-chan *Event, bufSize int, fn Callback, stats *emitterStats) *observable {
 	if bufSize <= 0 {
 		bufSize = 128
 	}
@@ -186,6 +252,7 @@ func newObservable(index uint64, input <-chan *Event, bufSize int, fn Callback)
 		output: output,
 		fn:     fn,
 		index:  index,
+		stats:  stats,
 	}
 
 	go r.consume()
@@ -195,11 +262,18 @@ func newObservable(index uint64, input <-chan *Event, bufSize int, fn Callback)
 
 func (r *observable) run() {
 	for v := range r.input {
+		// 先增加队列长度再入队，否则 consume 可能先减少，使其短暂为负
+		r.stats.queueDepth.Add(1)
 		select {
 		case r.output <- v:
 		default:
-			x := <-r.output
-			fmt.Printf("(%d)Warning: drop event: %v\n", r.index, x)
+			// 队列已满，丢弃最早的事件，consume 可能已取走，所以不能阻塞
+			select {
+			case <-r.output:
+				r.stats.queueDepth.Add(-1)
+				r.stats.dropped.Inc()
+			default:
+			}
 			r.output <- v
 		}
 	}
@@ -210,6 +284,7 @@ func (r *observable) run() {
 
 func (r *observable) consume() {
 	for e := range r.output {
+		r.stats.queueDepth.Add(-1)
 		fmt.Printf("(%d)consume 1----> %v\n", r.index, e)
 		r.fn(e)
 		fmt.Printf("(%d)consume 2----> %v\n", r.index, e)
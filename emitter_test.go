@@ -0,0 +1,166 @@
+package sdk
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bolaxytools/tool-sdk/metrics"
+)
+
+// notifyMetrics signal dropped events and keep the lowest queue depth
+type notifyMetrics struct {
+	metrics.Metrics
+	dropped chan struct{}
+
+	mu       sync.Mutex
+	depth    float64
+	minDepth float64
+}
+
+func (m *notifyMetrics) Counter(name, help string, labels metrics.Labels) metrics.Counter {
+	c := m.Metrics.Counter(name, help, labels)
+	if name == "bolaxy_emitter_dropped_events_total" {
+		return &notifyCounter{Counter: c, ch: m.dropped}
+	}
+	return c
+}
+
+func (m *notifyMetrics) Gauge(name, help string, labels metrics.Labels) metrics.Gauge {
+	g := m.Metrics.Gauge(name, help, labels)
+	if name == "bolaxy_emitter_queue_depth" {
+		return &depthGauge{Gauge: g, m: m}
+	}
+	return g
+}
+
+type notifyCounter struct {
+	metrics.Counter
+	ch chan struct{}
+}
+
+func (c *notifyCounter) Inc() {
+	c.Counter.Inc()
+	c.ch <- struct{}{}
+}
+
+type depthGauge struct {
+	metrics.Gauge
+	m *notifyMetrics
+}
+
+func (g *depthGauge) Add(delta float64) {
+	g.Gauge.Add(delta)
+
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	g.m.depth += delta
+	if g.m.depth < g.m.minDepth {
+		g.m.minDepth = g.m.depth
+	}
+}
+
+func (m *notifyMetrics) lowestDepth() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.minDepth
+}
+
+func TestEmitter_Metrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := &notifyMetrics{Metrics: registry, dropped: make(chan struct{}, 1)}
+	ee := NewEventEmitter(1, WithEmitterMetrics(m))
+
+	started := make(chan struct{}, 1)
+	block := make(chan struct{})
+	evtType := Type("test")
+	cancel := ee.On(func(*Event) {
+		started <- struct{}{}
+		<-block
+	}, evtType)
+
+	// the first event blocks the callback, the second fills the queue, the third drops the second
+	ee.Emit(NewEvent(evtType, 0))
+	wait(t, started, "callback")
+	ee.Emit(NewEvent(evtType, 1))
+	ee.Emit(NewEvent(evtType, 2))
+	wait(t, m.dropped, "dropped event")
+
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+	for _, line := range []string{
+		"bolaxy_emitter_subscribers 1",
+		"bolaxy_emitter_events_total 3",
+		"bolaxy_emitter_dropped_events_total 1",
+		"bolaxy_emitter_queue_depth 1",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("metric %q not found in:\n%s", line, buf.String())
+		}
+	}
+
+	close(block)
+	cancel()
+}
+
+func TestEmitter_QueueDepth(t *testing.T) {
+	m := &notifyMetrics{Metrics: metrics.NewRegistry(), dropped: make(chan struct{}, 1000)}
+	ee := NewEventEmitter(4, WithEmitterMetrics(m))
+
+	const count = 1000
+	evtType := Type("test")
+	var wg sync.WaitGroup
+	wg.Add(count)
+	cancel := ee.On(func(*Event) { wg.Done() }, evtType)
+	defer cancel()
+
+	// the queue is long enough for the burst only if the callback keeps up, count the dropped ones as done
+	go func() {
+		for range m.dropped {
+			wg.Done()
+		}
+	}()
+	for i := 0; i < count; i++ {
+		ee.Emit(NewEvent(evtType, i))
+	}
+	wg.Wait()
+
+	if min := m.lowestDepth(); min < 0 {
+		t.Fatalf("queue depth went negative: %v", min)
+	}
+}
+
+func wait(t *testing.T, ch <-chan struct{}, what string) {
+	select {
+	case <-ch:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("timeout waiting for %s", what)
+	}
+}
+
+func TestEmitter_OnceSubscribers(t *testing.T) {
+	registry := metrics.NewRegistry()
+	ee := NewEventEmitter(4, WithEmitterMetrics(registry))
+
+	fired := make(chan *Event, 2)
+	cancel := ee.Once(func(e *Event) { fired <- e }, Type("a"), Type("b"), Type("a"))
+	ee.Emit(NewEvent(Type("a"), 1))
+	<-fired
+	// the subscription is removed on all types when it fires, cancel is a no-op
+	ee.Emit(NewEvent(Type("b"), 2))
+	cancel()
+
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+	if !strings.Contains(buf.String(), "bolaxy_emitter_subscribers 0\n") {
+		t.Fatalf("subscribers gauge is not 0:\n%s", buf.String())
+	}
+
+	select {
+	case e := <-fired:
+		t.Fatalf("once subscription fired again: %v", e.GetValue())
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -12,6 +12,7 @@ require (
 	github.com/davecgh/go-spew v1.1.1
 	github.com/mitchellh/mapstructure v1.1.2
 	github.com/pborman/uuid v1.2.0
-	github.com/pkg/errors v0.8.1
+	github.com/pkg/errors v0.9.1
 	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24
+	github.com/tyler-smith/go-bip39 v1.0.2
 )
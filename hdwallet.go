@@ -0,0 +1,163 @@
+package sdk
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tyler-smith/go-bip39"
+
+	"github.com/bolaxy/common/math"
+	"github.com/bolaxy/crypto"
+)
+
+const (
+	// HardenedKeyStart BIP-32 强化派生的起始索引
+	HardenedKeyStart uint32 = 0x80000000
+	// DefaultBaseDerivationPath BIP-44 默认基础路径，末级为账户序号
+	DefaultBaseDerivationPath = "m/44'/60'/0'/0"
+)
+
+var (
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	ErrInvalidChildKey = errors.New("invalid child key, use next index")
+
+	masterKeySalt = []byte("Bitcoin seed")
+)
+
+// DerivationPath BIP-32 派生路径，每一级为子密钥索引
+type DerivationPath []uint32
+
+// ParseDerivationPath 解析形如 m/44'/60'/0'/0/0 的派生路径
+func ParseDerivationPath(path string) (DerivationPath, error) {
+	components := strings.Split(strings.TrimSpace(path), "/")
+	if len(components) == 0 || components[0] != "m" {
+		return nil, fmt.Errorf("derivation path must start with m: %s", path)
+	}
+
+	result := make(DerivationPath, 0, len(components)-1)
+	for _, component := range components[1:] {
+		component = strings.TrimSpace(component)
+		var offset uint32
+		if strings.HasSuffix(component, "'") || strings.HasSuffix(component, "H") {
+			offset = HardenedKeyStart
+			component = component[:len(component)-1]
+		}
+
+		value, err := strconv.ParseUint(component, 10, 32)
+		if err != nil || uint32(value) >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid derivation path component %q", component)
+		}
+		result = append(result, uint32(value)+offset)
+	}
+	return result, nil
+}
+
+// DefaultDerivationPath 返回 m/44'/60'/0'/0/index
+func DefaultDerivationPath(index uint32) DerivationPath {
+	return DerivationPath{44 + HardenedKeyStart, 60 + HardenedKeyStart, HardenedKeyStart, 0, index}
+}
+
+// String 返回 m/44'/60'/0'/0/0 格式的路径
+func (path DerivationPath) String() string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, component := range path {
+		b.WriteString("/")
+		if component >= HardenedKeyStart {
+			b.WriteString(strconv.FormatUint(uint64(component-HardenedKeyStart), 10))
+			b.WriteString("'")
+		} else {
+			b.WriteString(strconv.FormatUint(uint64(component), 10))
+		}
+	}
+	return b.String()
+}
+
+// NewMnemonic 生成 BIP-39 助记词。bitSize 为熵的位数，取值 128 ~ 256 且为 32 的倍数。
+func NewMnemonic(bitSize int) (string, error) {
+	entropy, err := bip39.NewEntropy(bitSize)
+	if err != nil {
+		return "", errors.Wrap(err, "new entropy")
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+// IsMnemonicValid 校验助记词的单词和校验和
+func IsMnemonicValid(mnemonic string) bool {
+	_, err := bip39.EntropyFromMnemonic(mnemonic)
+	return err == nil
+}
+
+// MnemonicToSeed 由助记词和口令生成 BIP-39 种子
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if !IsMnemonicValid(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+
+	return bip39.NewSeed(mnemonic, passphrase), nil
+}
+
+// DeriveKey 由种子按 BIP-32 派生路径派生密钥
+func DeriveKey(seed []byte, path DerivationPath) (*Key, error) {
+	mac := hmac.New(sha512.New, masterKeySalt)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	curveN := crypto.S256().Params().N
+	priv, chainCode := new(big.Int).SetBytes(sum[:32]), sum[32:]
+	if priv.Sign() == 0 || priv.Cmp(curveN) >= 0 {
+		return nil, errors.New("invalid master key")
+	}
+
+	for _, index := range path {
+		var data []byte
+		if index >= HardenedKeyStart {
+			data = append([]byte{0x00}, math.PaddedBigBytes(priv, 32)...)
+		} else {
+			pk, err := crypto.ToECDSA(math.PaddedBigBytes(priv, 32))
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&pk.PublicKey)
+		}
+
+		var seq [4]byte
+		binary.BigEndian.PutUint32(seq[:], index)
+		data = append(data, seq[:]...)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		il := new(big.Int).SetBytes(sum[:32])
+		if il.Cmp(curveN) >= 0 {
+			return nil, ErrInvalidChildKey
+		}
+
+		priv = il.Add(il, priv)
+		priv.Mod(priv, curveN)
+		if priv.Sign() == 0 {
+			return nil, ErrInvalidChildKey
+		}
+		chainCode = sum[32:]
+	}
+
+	return RecoverKey(math.PaddedBigBytes(priv, 32))
+}
+
+// RecoverKeyFromMnemonic 由助记词和口令派生 m/44'/60'/0'/0/index 路径下的密钥
+func RecoverKeyFromMnemonic(mnemonic, passphrase string, index uint32) (*Key, error) {
+	seed, err := MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return DeriveKey(seed, DefaultDerivationPath(index))
+}
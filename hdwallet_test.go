@@ -0,0 +1,52 @@
+package sdk
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDerivationPath(t *testing.T) {
+	path, err := ParseDerivationPath(DefaultBaseDerivationPath + "/3")
+	if err != nil {
+		t.Fatalf("ParseDerivationPath: %v", err)
+	}
+
+	if path.String() != DefaultDerivationPath(3).String() {
+		t.Fatalf("path mismatch: have %s, want %s", path, DefaultDerivationPath(3))
+	}
+
+	for _, invalid := range []string{"", "44'/60'", "m/x", "m/2147483648"} {
+		if _, err := ParseDerivationPath(invalid); err == nil {
+			t.Fatalf("ParseDerivationPath(%q): expected error", invalid)
+		}
+	}
+}
+
+func TestRecoverKeyFromMnemonic(t *testing.T) {
+	mnemonic := strings.Repeat("abandon ", 11) + "about"
+
+	key, err := RecoverKeyFromMnemonic(mnemonic, "", 0)
+	if err != nil {
+		t.Fatalf("RecoverKeyFromMnemonic: %v", err)
+	}
+
+	want := "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+	if key.GetStringAddress() != want {
+		t.Fatalf("address mismatch: have %s, want %s", key.GetStringAddress(), want)
+	}
+
+	if _, err := RecoverKeyFromMnemonic(strings.Repeat("abandon ", 12), "", 0); err != ErrInvalidMnemonic {
+		t.Fatalf("RecoverKeyFromMnemonic: have %v, want %v", err, ErrInvalidMnemonic)
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	mnemonic, err := NewMnemonic(128)
+	if err != nil {
+		t.Fatalf("NewMnemonic: %v", err)
+	}
+
+	if !IsMnemonicValid(mnemonic) || len(strings.Fields(mnemonic)) != 12 {
+		t.Fatalf("invalid mnemonic: %s", mnemonic)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"math/big"
 
+	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
 
 	"github.com/bolaxy/accounts/keystore"
@@ -18,10 +19,6 @@ const (
 	p = keystore.StandardScryptP
 )
 
-var (
-	signer = types.NewEIP155Signer(big.NewInt(1))
-)
-
 func GenerateKey() (*Key, error) {
 	pk, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
 	if err != nil {
@@ -43,6 +40,35 @@ func (k *Key) ExportPrivateKey() []byte {
 	return crypto.FromECDSA(k.PK)
 }
 
+// EncryptJSON 用口令加密密钥，返回 Web3 Secret Storage v3 格式的 keystore 文件内容。
+func (k *Key) EncryptJSON(passphrase string) ([]byte, error) {
+	if k.id == nil {
+		k.id = uuid.NewRandom()
+	}
+
+	keyjson, err := keystore.EncryptKey(&keystore.Key{
+		Id:         k.id,
+		Address:    k.address,
+		PrivateKey: k.PK,
+	}, passphrase, n, p)
+	if err != nil {
+		return nil, errors.Wrap(err, "encrypt key")
+	}
+	return keyjson, nil
+}
+
+// DecryptKeyJSON 用口令解密 keystore 文件内容，恢复出密钥。
+func DecryptKeyJSON(keyjson []byte, passphrase string) (*Key, error) {
+	k, err := keystore.DecryptKey(keyjson, passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt key")
+	}
+
+	key := newKey(k.PrivateKey)
+	key.id = k.Id
+	return key, nil
+}
+
 // GetAddress 获取hex格式账户地址字符串
 func (k *Key) GetStringAddress() string {
 	if len(k.Address) == 0 {
@@ -55,9 +81,22 @@ func (k *Key) GetAddress() common.Address {
 	return k.address
 }
 
+// SetChainID 设置签名使用的链ID，未设置时使用 common.ChainID
+func (k *Key) SetChainID(chainID *big.Int) {
+	k.chainID = chainID
+}
+
+// ChainID 返回签名使用的链ID
+func (k *Key) ChainID() *big.Int {
+	if k.chainID == nil {
+		return common.ChainID
+	}
+	return k.chainID
+}
+
 // SignTx 用密钥对交易数据签名。返回签名后的交易数据。
 func (k *Key) SignTx(tx *types.Transaction) (*types.Transaction, error) {
-	return types.SignTx(tx, signer, k.PK)
+	return types.SignTx(tx, types.NewEIP155Signer(k.ChainID()), k.PK)
 }
 
 type Key struct {
@@ -65,6 +104,8 @@ type Key struct {
 	PK      *ecdsa.PrivateKey
 
 	address common.Address
+	id      uuid.UUID
+	chainID *big.Int
 }
 
 func newKey(pk *ecdsa.PrivateKey) *Key {
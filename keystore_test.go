@@ -0,0 +1,34 @@
+package sdk
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeyEncryptDecryptJSON(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	keyjson, err := key.EncryptJSON("foo")
+	if err != nil {
+		t.Fatalf("EncryptJSON: %v", err)
+	}
+
+	if _, err := DecryptKeyJSON(keyjson, "bar"); err == nil {
+		t.Fatalf("DecryptKeyJSON: expected error with wrong passphrase")
+	}
+
+	recovered, err := DecryptKeyJSON(keyjson, "foo")
+	if err != nil {
+		t.Fatalf("DecryptKeyJSON: %v", err)
+	}
+
+	if recovered.GetAddress() != key.GetAddress() {
+		t.Fatalf("address mismatch: have %s, want %s", recovered.GetAddress().String(), key.GetAddress().String())
+	}
+	if !bytes.Equal(recovered.ExportPrivateKey(), key.ExportPrivateKey()) {
+		t.Fatalf("private key mismatch")
+	}
+}
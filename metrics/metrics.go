@@ -0,0 +1,70 @@
+// Package metrics the metrics interface of the sdk, rpc client and block monitor.
+//
+// the default implementation Registry keeps metrics in memory and exposes them in
+// the prometheus text format by Handler, so no external service is required:
+//
+//	registry := metrics.NewRegistry()
+//	client := rpc.Dial(host, rpc.WithMetrics(registry))
+//	http.Handle("/metrics", registry.Handler())
+//
+// other backends can be plugged in by implementing Metrics.
+package metrics
+
+// Labels the label names and values of a metric series
+type Labels map[string]string
+
+// Counter a value that only goes up
+type Counter interface {
+	Inc()
+	Add(delta float64)
+}
+
+// Gauge a value that can go up and down
+type Gauge interface {
+	Set(value float64)
+	Add(delta float64)
+}
+
+// Histogram count observations in buckets
+type Histogram interface {
+	Observe(value float64)
+}
+
+// Metrics create or get the metric series by name and labels,
+// the same name and labels always return the same series
+type Metrics interface {
+	Counter(name, help string, labels Labels) Counter
+	Gauge(name, help string, labels Labels) Gauge
+	// Histogram buckets are the upper bounds in increasing order, DefaultBuckets if nil
+	Histogram(name, help string, buckets []float64, labels Labels) Histogram
+}
+
+// DefaultBuckets the default histogram buckets of latency in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Nop discard all metrics, it is used when no metrics is set
+var Nop Metrics = nop{}
+
+// Or return m, or Nop if m is nil
+func Or(m Metrics) Metrics {
+	if m == nil {
+		return Nop
+	}
+	return m
+}
+
+type nop struct{}
+
+func (nop) Counter(name, help string, labels Labels) Counter { return nop{} }
+
+func (nop) Gauge(name, help string, labels Labels) Gauge { return nop{} }
+
+func (nop) Histogram(name, help string, buckets []float64, labels Labels) Histogram { return nop{} }
+
+func (nop) Inc() {}
+
+func (nop) Add(float64) {}
+
+func (nop) Set(float64) {}
+
+func (nop) Observe(float64) {}
@@ -0,0 +1,242 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// Registry in-memory Metrics which can be exposed in the prometheus text format
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labels string
+
+	mu      sync.Mutex
+	value   float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+	buckets []float64
+}
+
+// NewRegistry create empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Counter see Metrics.Counter, it panics if name is registered as another type
+func (r *Registry) Counter(name, help string, labels Labels) Counter {
+	return (*counter)(r.series(name, help, typeCounter, nil, labels))
+}
+
+// Gauge see Metrics.Gauge, it panics if name is registered as another type
+func (r *Registry) Gauge(name, help string, labels Labels) Gauge {
+	return (*gauge)(r.series(name, help, typeGauge, nil, labels))
+}
+
+// Histogram see Metrics.Histogram, it panics if name is registered as another type
+func (r *Registry) Histogram(name, help string, buckets []float64, labels Labels) Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return (*histogram)(r.series(name, help, typeHistogram, buckets, labels))
+}
+
+func (r *Registry) series(name, help, typ string, buckets []float64, labels Labels) *series {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, typ: typ, buckets: buckets, series: make(map[string]*series)}
+		r.families[name] = f
+	} else if f.typ != typ {
+		panic(fmt.Sprintf("metrics: %s is registered as %s, not %s", name, f.typ, typ))
+	}
+
+	key := formatLabels(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: key, buckets: f.buckets}
+		if typ == typeHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// WriteText write all metrics in the prometheus text exposition format, sorted by name and labels
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		r.mu.Lock()
+		list := make([]*series, 0, len(f.series))
+		for _, s := range f.series {
+			list = append(list, s)
+		}
+		r.mu.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].labels < list[j].labels })
+
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.typ)
+		for _, s := range list {
+			s.write(bw, f)
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler http handler serving the text exposition format, e.g. on /metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+func (s *series) write(w io.Writer, f *family) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f.typ != typeHistogram {
+		fmt.Fprintf(w, "%s%s %s\n", f.name, s.labels, formatFloat(s.value))
+		return
+	}
+
+	var cumulative uint64
+	for i, bound := range s.buckets {
+		cumulative += s.counts[i]
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, withLabel(s.labels, "le", formatFloat(bound)), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, withLabel(s.labels, "le", "+Inf"), s.count)
+	fmt.Fprintf(w, "%s_sum%s %s\n", f.name, s.labels, formatFloat(s.sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", f.name, s.labels, s.count)
+}
+
+type counter series
+
+func (c *counter) Inc() {
+	c.Add(1)
+}
+
+// Add negative delta is ignored, a counter never goes down
+func (c *counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.value += delta
+	c.mu.Unlock()
+}
+
+type gauge series
+
+func (g *gauge) Set(value float64) {
+	g.mu.Lock()
+	g.value = value
+	g.mu.Unlock()
+}
+
+func (g *gauge) Add(delta float64) {
+	g.mu.Lock()
+	g.value += delta
+	g.mu.Unlock()
+}
+
+type histogram series
+
+func (h *histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := sort.SearchFloat64s(h.buckets, value)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+// formatLabels format labels as {a="1",b="2"} sorted by name, empty if no labels
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(labels[name])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + escapeLabel(value) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+// escapeLabel escape the label value in the text exposition format, other characters are written as is
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("requests_total", "Total requests.", Labels{"path": "/info", "endpoint": "a"}).Add(2)
+	r.Counter("requests_total", "Total requests.", Labels{"endpoint": "a", "path": "/info"}).Inc()
+	r.Counter("requests_total", "Total requests.", Labels{"endpoint": "a", "path": "/info"}).Add(-1)
+	r.Gauge("height", "Block height.", nil).Set(10)
+	r.Gauge("height", "Block height.", nil).Add(-2)
+	h := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1}, nil)
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(2)
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+
+	want := `# HELP height Block height.
+# TYPE height gauge
+height 8
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 2.55
+latency_seconds_count 3
+# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{endpoint="a",path="/info"} 3
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected text:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") || rec.Body.String() != want {
+		t.Fatalf("unexpected response: %s", rec.Body.String())
+	}
+}
+
+func TestRegistry_TypeConflict(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("x", "", nil)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	r.Gauge("x", "", nil)
+}
+
+func TestRegistry_EscapeLabels(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("errors_total", "", Labels{"monitor": "区块\t\"a\\b\"\nc"}).Inc()
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	// only backslash, double quote and newline are escaped in label values
+	want := "errors_total{monitor=\"区块\t\\\"a\\\\b\\\"\\nc\"} 1\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Fatalf("unexpected text:\n%s\nwant suffix:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/core/types"
+
+	"github.com/bolaxytools/tool-sdk"
+)
+
+// API bolaxy node api, implemented by Client and Pool
+// code depending on API can be tested with the fake node in package rpctest.
+type API interface {
+	// ChainID the chain id used to sign and verify transactions
+	ChainID() *big.Int
+
+	Transact(data string) (*RawTxRes, error)
+	TransactContext(ctx context.Context, data string) (*RawTxRes, error)
+	IsContract(address string) (bool, error)
+	IsContractContext(ctx context.Context, address string) (bool, error)
+	FetchNonce(address string) (uint64, error)
+	FetchNonceContext(ctx context.Context, address string) (uint64, error)
+	FetchBalance(address string) (*big.Int, error)
+	FetchBalanceContext(ctx context.Context, address string) (*big.Int, error)
+	FetchAccount(address string) (*JsonAccount, error)
+	FetchAccountContext(ctx context.Context, address string) (*JsonAccount, error)
+	FetchBlock(index int) (*types.Block, error)
+	FetchBlockContext(ctx context.Context, index int) (*types.Block, error)
+	FetchReceipt(txhash string) (*JsonReceipt, error)
+	FetchReceiptContext(ctx context.Context, txhash string) (*JsonReceipt, error)
+	FetchChainInfo() (*ChainMeta, error)
+	FetchChainInfoContext(ctx context.Context) (*ChainMeta, error)
+	CallContract(msg *SendTxArgs) ([]byte, error)
+	CallContractContext(ctx context.Context, msg *SendTxArgs) ([]byte, error)
+
+	FetchBlocks(ctx context.Context, from, to int, opts ...BlocksOpt) ([]*types.Block, error)
+	StreamBlocks(ctx context.Context, from, to int, opts ...BlocksOpt) (<-chan *types.Block, <-chan error)
+
+	SendTransaction(ctx context.Context, signer sdk.Signer, args *SendTxArgs, opts ...SendOpt) (*SendTxResult, error)
+	WaitReceipt(ctx context.Context, txhash common.Hash, pollInterval time.Duration) (*JsonReceipt, error)
+}
+
+var (
+	_ API = (*Client)(nil)
+	_ API = (*Pool)(nil)
+)
@@ -0,0 +1,165 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/bolaxy/core/types"
+	"github.com/pkg/errors"
+)
+
+var (
+	defaultBlocksConcurrency = 8
+
+	ErrInvalidRange = errors.New("invalid block range")
+)
+
+// BlocksOpt options of FetchBlocks and StreamBlocks
+type BlocksOpt func(opts *blocksOpts)
+
+type blocksOpts struct {
+	concurrency int
+	// height the known last_block_index, the chain info is fetched if it is not set
+	height *int
+}
+
+// WithConcurrency set the max number of blocks fetched at the same time, default is 8
+func WithConcurrency(concurrency int) BlocksOpt {
+	return func(opts *blocksOpts) {
+		opts.concurrency = concurrency
+	}
+}
+
+// withHeight use height as last_block_index instead of fetching the chain info
+func withHeight(height int) BlocksOpt {
+	return func(opts *blocksOpts) {
+		opts.height = &height
+	}
+}
+
+// FetchBlocks fetch the blocks from index from to index to, both inclusive, in order.
+// to is limited to last_block_index of the chain, to < 0 means the last block.
+// each block is fetched with the retry policy of the client, the first failure aborts the others.
+func (c *Client) FetchBlocks(ctx context.Context, from, to int, opts ...BlocksOpt) ([]*types.Block, error) {
+	return fetchBlocks(ctx, c, from, to, opts...)
+}
+
+// StreamBlocks same as FetchBlocks, but send the blocks over a channel in order as soon as they are available.
+// the block channel is closed when all blocks are sent or an error occurs,
+// the error channel receives at most one error and is closed after the block channel.
+// the caller must drain the block channel or cancel ctx.
+func (c *Client) StreamBlocks(ctx context.Context, from, to int, opts ...BlocksOpt) (<-chan *types.Block, <-chan error) {
+	return streamBlocks(ctx, c, from, to, opts...)
+}
+
+func fetchBlocks(ctx context.Context, c API, from, to int, opts ...BlocksOpt) ([]*types.Block, error) {
+	blocks, errc := streamBlocks(ctx, c, from, to, opts...)
+
+	var ret []*types.Block
+	for blk := range blocks {
+		ret = append(ret, blk)
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+type blockResult struct {
+	index int
+	blk   *types.Block
+	err   error
+}
+
+func streamBlocks(ctx context.Context, c API, from, to int, opts ...BlocksOpt) (<-chan *types.Block, <-chan error) {
+	options := &blocksOpts{concurrency: defaultBlocksConcurrency}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.concurrency < 1 {
+		options.concurrency = 1
+	}
+
+	out := make(chan *types.Block, options.concurrency)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(out)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		to, err := blocksRange(ctx, c, from, to, options.height)
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		// pending keeps the results in order, together with the one being waited for,
+		// at most concurrency blocks are fetched at the same time
+		pending := make(chan chan *blockResult, options.concurrency-1)
+		go func() {
+			defer close(pending)
+			for i := from; i <= to; i++ {
+				res := make(chan *blockResult, 1)
+				select {
+				case pending <- res:
+				case <-ctx.Done():
+					return
+				}
+
+				go func(i int) {
+					blk, err := c.FetchBlockContext(ctx, i)
+					res <- &blockResult{index: i, blk: blk, err: err}
+				}(i)
+			}
+		}()
+
+		for res := range pending {
+			r := <-res
+			if r.err != nil {
+				errc <- errors.Wrapf(r.err, "fetchBlocks[%d]", r.index)
+				return
+			}
+
+			select {
+			case out <- r.blk:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			errc <- err
+		}
+	}()
+
+	return out, errc
+}
+
+// blocksRange check the range and limit to to the chain height,
+// the chain info is fetched if height is nil
+func blocksRange(ctx context.Context, c API, from, to int, height *int) (int, error) {
+	if from < 0 || (to >= 0 && to < from) {
+		return 0, errors.Wrapf(ErrInvalidRange, "[%d, %d]", from, to)
+	}
+
+	if height == nil {
+		info, err := c.FetchChainInfoContext(ctx)
+		if err != nil {
+			return 0, errors.Wrap(err, "fetchBlocks[chainInfo]")
+		}
+
+		last, err := info.LastBlockIndex()
+		if err != nil {
+			return 0, errors.Wrap(err, "fetchBlocks[parse last_block_index]")
+		}
+		h := int(last)
+		height = &h
+	}
+
+	if to < 0 || to > *height {
+		to = *height
+	}
+	return to, nil
+}
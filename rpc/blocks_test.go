@@ -0,0 +1,102 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bolaxytools/tool-sdk/rpc"
+	"github.com/bolaxytools/tool-sdk/rpc/rpctest"
+)
+
+func TestClient_FetchBlocks(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	for i := 0; i < 20; i++ {
+		node.AddBlock()
+	}
+
+	var inflight, maxInflight int32
+	client := node.Client(rpc.WithRetry(nil), rpc.WithInterceptor(&rpc.Interceptor{
+		BeforeRequest: func(req *http.Request) error {
+			if !strings.HasPrefix(req.URL.Path, "/block/") {
+				return nil
+			}
+			n := atomic.AddInt32(&inflight, 1)
+			for {
+				max := atomic.LoadInt32(&maxInflight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInflight, max, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		},
+		AfterResponse: func(req *http.Request, resp *http.Response, body []byte, elapsed time.Duration) error {
+			if strings.HasPrefix(req.URL.Path, "/block/") {
+				atomic.AddInt32(&inflight, -1)
+			}
+			return nil
+		},
+	}))
+
+	// to beyond the chain height is limited to the last block
+	blocks, err := client.FetchBlocks(context.Background(), 0, 100, rpc.WithConcurrency(3))
+	if err != nil {
+		t.Fatalf("FetchBlocks: %v", err)
+	}
+	if len(blocks) != 21 {
+		t.Fatalf("blocks: have %d, want 21", len(blocks))
+	}
+	for i, blk := range blocks {
+		if blk.Index() != i {
+			t.Fatalf("block %d: have index %d", i, blk.Index())
+		}
+	}
+	if max := atomic.LoadInt32(&maxInflight); max > 3 || max < 2 {
+		t.Fatalf("max concurrent requests: have %d, want 2..3", max)
+	}
+
+	// stream until the last block
+	stream, errc := client.StreamBlocks(context.Background(), 18, -1)
+	next := 18
+	for blk := range stream {
+		if blk.Index() != next {
+			t.Fatalf("stream: have block %d, want %d", blk.Index(), next)
+		}
+		next++
+	}
+	if err := <-errc; err != nil || next != 21 {
+		t.Fatalf("StreamBlocks: stopped at %d, %v", next, err)
+	}
+
+	if _, err := client.FetchBlocks(context.Background(), 5, 4); !errors.Is(err, rpc.ErrInvalidRange) {
+		t.Fatalf("FetchBlocks: have %v, want ErrInvalidRange", err)
+	}
+
+	// the first failure aborts the range, the blocks before it are streamed
+	errFault := errors.New("injected fault")
+	faulty := node.Client(rpc.WithRetry(nil), rpc.WithInterceptor(&rpc.Interceptor{
+		BeforeRequest: func(req *http.Request) error {
+			if req.URL.Path == "/block/15" {
+				return errFault
+			}
+			return nil
+		},
+	}))
+	stream, errc = faulty.StreamBlocks(context.Background(), 10, 20, rpc.WithConcurrency(4))
+	count := 0
+	for range stream {
+		count++
+	}
+	if err := <-errc; !errors.Is(err, errFault) || !strings.Contains(err.Error(), "fetchBlocks[15]") {
+		t.Fatalf("StreamBlocks: have %v, want fault of block 15", err)
+	}
+	if count != 5 {
+		t.Fatalf("streamed blocks: have %d, want 5", count)
+	}
+}
@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidCheckpoint the saved checkpoint can not be parsed, e.g. it is truncated by a crash.
+// the monitor reports it and does not scan until the checkpoint is fixed,
+// unless WithCheckpointFallback is set
+var ErrInvalidCheckpoint = errors.New("invalid checkpoint")
+
+// Checkpointer persist the progress of blkMonitor.
+// the monitor saves the index of the last block of every batch after all its events are emitted,
+// and resumes from the block after the saved one on Start, so every event is delivered at least once;
+// the blocks of a batch interrupted by a crash are delivered again.
+type Checkpointer interface {
+	// Load return the index of the last processed block, ok is false if nothing is saved
+	Load() (index uint64, ok bool, err error)
+	// Save record index as the last processed block
+	Save(index uint64) error
+}
+
+// WithCheckpointer set the checkpointer of the monitor, the saved checkpoint takes precedence over WithStartIndex
+func WithCheckpointer(checkpointer Checkpointer) MonitorOpt {
+	return func(monitor *blkMonitor) {
+		monitor.checkpointer = checkpointer
+	}
+}
+
+// WithCheckpointFallback start from WithStartIndex if the saved checkpoint is invalid, see ErrInvalidCheckpoint.
+// the invalid checkpoint is still reported, and the events from the start index are delivered again
+func WithCheckpointFallback() MonitorOpt {
+	return func(monitor *blkMonitor) {
+		monitor.checkpointFallback = true
+	}
+}
+
+// MemoryCheckpointer keep the checkpoint in memory, e.g. to share progress between monitors in a process
+type MemoryCheckpointer struct {
+	mu    sync.Mutex
+	index uint64
+	ok    bool
+}
+
+// NewMemoryCheckpointer create empty memory checkpointer
+func NewMemoryCheckpointer() *MemoryCheckpointer {
+	return &MemoryCheckpointer{}
+}
+
+// Load see Checkpointer.Load
+func (c *MemoryCheckpointer) Load() (uint64, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.index, c.ok, nil
+}
+
+// Save see Checkpointer.Save
+func (c *MemoryCheckpointer) Save(index uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.index = index
+	c.ok = true
+	return nil
+}
+
+// FileCheckpointer keep the checkpoint as a decimal number in a file,
+// the file is replaced atomically on each Save
+type FileCheckpointer struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileCheckpointer create file checkpointer, the file is created on the first Save
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+// Load see Checkpointer.Load
+func (c *FileCheckpointer) Load() (uint64, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.Wrap(err, "read checkpoint")
+	}
+
+	index, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, errors.Wrapf(ErrInvalidCheckpoint, "parse %s: %v", c.path, err)
+	}
+	return index, true, nil
+}
+
+// Save see Checkpointer.Save
+func (c *FileCheckpointer) Save(index uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, err := ioutil.TempFile(filepath.Dir(c.path), "."+filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "create checkpoint")
+	}
+	// the content is synced before rename, so a crash leaves either the old or the new checkpoint
+	_, err = f.WriteString(strconv.FormatUint(index, 10) + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return errors.Wrap(err, "write checkpoint")
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "write checkpoint")
+	}
+
+	if err := os.Rename(f.Name(), c.path); err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "rename checkpoint")
+	}
+	return syncDir(filepath.Dir(c.path))
+}
+
+// syncDir persist the rename in dir
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrap(err, "sync checkpoint dir")
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return errors.Wrap(err, "sync checkpoint dir")
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package rpc_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bolaxy/common"
+
+	"github.com/bolaxytools/tool-sdk"
+	"github.com/bolaxytools/tool-sdk/rpc"
+)
+
+func TestFileCheckpointer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "monitor.checkpoint")
+	cp := rpc.NewFileCheckpointer(path)
+	if _, ok, err := cp.Load(); ok || err != nil {
+		t.Fatalf("Load: have %v, %v, want no checkpoint", ok, err)
+	}
+
+	for _, index := range []uint64{7, 12345} {
+		if err := cp.Save(index); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		// a new checkpointer reads what is saved by the last one
+		loaded, ok, err := rpc.NewFileCheckpointer(path).Load()
+		if err != nil || !ok || loaded != index {
+			t.Fatalf("Load: have %d, %v, %v, want %d", loaded, ok, err, index)
+		}
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("temporary files are left: %d files", len(files))
+	}
+
+	// e.g. truncated by a crash
+	for _, content := range []string{"bad", ""} {
+		ioutil.WriteFile(path, []byte(content), 0644)
+		if _, _, err := cp.Load(); !errors.Is(err, rpc.ErrInvalidCheckpoint) {
+			t.Fatalf("Load %q: have %v, want ErrInvalidCheckpoint", content, err)
+		}
+	}
+}
+
+func TestBlkMonitor_InvalidCheckpoint(t *testing.T) {
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	var hashes []common.Hash
+	for i := 0; i < 3; i++ {
+		hashes = append(hashes, transfer(t, client, key, common.HexToAddress(testAddress), big.NewInt(1)).TxHash)
+	}
+
+	dir, err := ioutil.TempDir("", "checkpoint-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "monitor.checkpoint")
+	ioutil.WriteFile(path, nil, 0644)
+	cp := rpc.NewFileCheckpointer(path)
+
+	emitter := sdk.NewEventEmitter(16)
+	received, cancel := collectTxEvents(emitter, hashes)
+	defer cancel()
+
+	checkReported := func(err error) {
+		var monitorErr *rpc.MonitorError
+		if !errors.As(err, &monitorErr) || monitorErr.Stage != "checkpoint" || !errors.Is(err, rpc.ErrInvalidCheckpoint) {
+			t.Fatalf("reported error: %v", err)
+		}
+	}
+
+	// without the fallback the invalid checkpoint is reported and nothing is replayed
+	errc := make(chan error, 100)
+	monitor := rpc.NewBlkMonitor(emitter, client, rpc.WithPeriod(5*time.Millisecond), rpc.WithCheckpointer(cp),
+		rpc.WithStartIndex(2), rpc.WithErrorHandler(func(err error) {
+			select {
+			case errc <- err:
+			default:
+			}
+		}))
+	monitor.Start()
+	select {
+	case err := <-errc:
+		checkReported(err)
+	case <-time.After(3 * time.Second):
+		t.Fatalf("invalid checkpoint is not reported")
+	}
+	if health := monitor.Health(); health.Healthy {
+		t.Fatalf("monitor is healthy with an invalid checkpoint")
+	}
+	monitor.Stop()
+	if got := received(); len(got) != 0 {
+		t.Fatalf("events: have %v, want none", got)
+	}
+	if _, _, err := cp.Load(); !errors.Is(err, rpc.ErrInvalidCheckpoint) {
+		t.Fatalf("checkpoint: have %v, want ErrInvalidCheckpoint", err)
+	}
+
+	// with the fallback the monitor starts from the start index and overwrites the invalid checkpoint
+	errc = make(chan error, 100)
+	monitor = rpc.NewBlkMonitor(emitter, client, rpc.WithPeriod(5*time.Millisecond), rpc.WithCheckpointer(cp),
+		rpc.WithStartIndex(2), rpc.WithCheckpointFallback(), rpc.WithErrorHandler(func(err error) { errc <- err }))
+	monitor.Start()
+	defer monitor.Stop()
+
+	waitFor(t, "checkpoint", func() bool {
+		index, ok, _ := cp.Load()
+		return ok && index == 3
+	})
+	waitFor(t, "events", func() bool { return len(received()) == 2 })
+	if got := received(); got[0] != hashes[1] || got[1] != hashes[2] {
+		t.Fatalf("events: have %v, want from block 2", got)
+	}
+
+	select {
+	case err := <-errc:
+		checkReported(err)
+	default:
+		t.Fatalf("invalid checkpoint is not reported")
+	}
+}
+
+func TestBlkMonitor_Checkpoint(t *testing.T) {
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	var hashes []common.Hash
+	for i := 0; i < 3; i++ {
+		hashes = append(hashes, transfer(t, client, key, common.HexToAddress(testAddress), big.NewInt(1)).TxHash)
+	}
+
+	cp := rpc.NewMemoryCheckpointer()
+	emitter := sdk.NewEventEmitter(16)
+	received, cancel := collectTxEvents(emitter, hashes)
+	defer cancel()
+
+	monitor := rpc.NewBlkMonitor(emitter, client, rpc.WithPeriod(5*time.Millisecond), rpc.WithCheckpointer(cp))
+	monitor.Start()
+	waitFor(t, "checkpoint", func() bool {
+		index, ok, _ := cp.Load()
+		return ok && index == 3
+	})
+	monitor.Stop()
+	waitFor(t, "events", func() bool { return len(received()) == 3 })
+
+	// the restarted monitor resumes after the checkpoint, the start index is ignored
+	hashes = append(hashes, transfer(t, client, key, common.HexToAddress(testAddress), big.NewInt(1)).TxHash)
+	received, cancel = collectTxEvents(emitter, hashes)
+	defer cancel()
+
+	monitor = rpc.NewBlkMonitor(emitter, client, rpc.WithPeriod(5*time.Millisecond), rpc.WithCheckpointer(cp), rpc.WithStartIndex(1))
+	monitor.Start()
+	defer monitor.Stop()
+
+	waitFor(t, "checkpoint", func() bool {
+		index, _, _ := cp.Load()
+		return index == 4
+	})
+	waitFor(t, "events", func() bool { return len(received()) == 1 })
+	time.Sleep(20 * time.Millisecond)
+	if got := received(); len(got) != 1 || got[0] != hashes[3] {
+		t.Fatalf("events after restart: have %v, want only %s", got, hashes[3].String())
+	}
+}
+
+// countingCheckpointer count the saves of the wrapped checkpointer
+type countingCheckpointer struct {
+	rpc.Checkpointer
+	saves int32
+}
+
+func (c *countingCheckpointer) Save(index uint64) error {
+	atomic.AddInt32(&c.saves, 1)
+	return c.Checkpointer.Save(index)
+}
+
+func TestBlkMonitor_CheckpointPerBatch(t *testing.T) {
+	node, _, _ := newTestNode(t)
+	defer node.Close()
+
+	for i := 0; i < 100; i++ {
+		node.AddBlock()
+	}
+	height := uint64(node.BlockHeight())
+
+	cp := &countingCheckpointer{Checkpointer: rpc.NewMemoryCheckpointer()}
+	monitor := rpc.NewBlkMonitor(sdk.NewEventEmitter(16), node.Client(), rpc.WithPeriod(5*time.Millisecond),
+		rpc.WithCheckpointer(cp), rpc.WithBatchSize(30))
+	monitor.Start()
+	defer monitor.Stop()
+
+	waitFor(t, "checkpoint", func() bool {
+		index, ok, _ := cp.Load()
+		return ok && index == height
+	})
+	// the checkpoint is saved once per batch, not once per block
+	if saves, batches := atomic.LoadInt32(&cp.saves), int32(height/30+1); saves != batches {
+		t.Fatalf("checkpoint saved %d times, want %d", saves, batches)
+	}
+}
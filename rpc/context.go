@@ -1,14 +1,22 @@
 package rpc
 
 import (
+	"encoding/base64"
 	"math/big"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/hexutil"
 	ethTypes "github.com/bolaxy/eth/types"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
 )
 
 var (
-	defaultHost = "http://localhost:8080"
+	defaultHost    = "http://localhost:8080"
+	defaultTimeout = 30 * time.Second
 )
 
 const (
@@ -57,6 +65,115 @@ type ChainMeta struct {
 	UnDeterminedEvents string `mapstructure:"undetermined_events"`
 }
 
+// ChainInfo typed view of ChainMeta, see ChainMeta.Typed
+type ChainInfo struct {
+	EventsNum          uint64    `mapstructure:"consensus_events"`
+	EventRate          float64   `mapstructure:"events_per_second"`
+	TransactionsNum    uint64    `mapstructure:"consensus_transactions"`
+	Id                 uint64    `mapstructure:"id"`
+	BlockHeight        int64     `mapstructure:"last_block_index"`     // BlockHeight -1 if the chain has no block yet
+	ConsensusRound     int64     `mapstructure:"last_consensus_round"` // ConsensusRound -1 if no round is decided yet, i.e. "nil"
+	NodeName           string    `mapstructure:"moniker"`
+	PeersNum           uint64    `mapstructure:"num_peers"`
+	RoundEvents        uint64    `mapstructure:"round_events"`
+	RoundRate          float64   `mapstructure:"rounds_per_second"`
+	State              NodeState `mapstructure:"state"`
+	SyncRate           float64   `mapstructure:"sync_rate"`
+	InPoolNum          uint64    `mapstructure:"transaction_pool"`
+	Type               string    `mapstructure:"type"`
+	UnDeterminedEvents uint64    `mapstructure:"undetermined_events"`
+}
+
+// LastBlockIndex parse last_block_index, -1 if the chain has no block yet
+func (m *ChainMeta) LastBlockIndex() (int64, error) {
+	return parseIndex(m.BlockHeight)
+}
+
+// Typed decode the raw fields of ChainMeta by the chain info decode hooks.
+// the fields which fail to parse are left zero and the error is returned,
+// so the other fields are still usable
+func (m *ChainMeta) Typed() (ChainInfo, error) {
+	var info ChainInfo
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result: &info,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			stringToNodeState,
+			stringToIndex,
+			decimalToUint64,
+			stringToFloat64,
+		),
+	})
+	if err != nil {
+		return info, err
+	}
+
+	if err := decoder.Decode(m); err != nil {
+		return info, errors.Wrap(err, "parse chain info")
+	}
+	return info, nil
+}
+
+// parseIndex parse block index or round, "nil" and "" are -1
+func parseIndex(s string) (int64, error) {
+	if s == "" || s == "nil" {
+		return -1, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// NodeState the consensus state of node
+type NodeState int
+
+const (
+	// StateUnknown the state is not recognized
+	StateUnknown NodeState = iota
+	// StateBabbling node is in sync and taking part in consensus, it is Gossiping in old versions
+	StateBabbling
+	// StateCatchingUp node is fast syncing from peers
+	StateCatchingUp
+	// StateJoining node is joining the validator set
+	StateJoining
+	// StateLeaving node is leaving the validator set
+	StateLeaving
+	// StateSuspended node stops taking part in consensus
+	StateSuspended
+	// StateShutdown node is shut down
+	StateShutdown
+)
+
+var nodeStates = map[string]NodeState{
+	"Babbling":   StateBabbling,
+	"Gossiping":  StateBabbling,
+	"CatchingUp": StateCatchingUp,
+	"Joining":    StateJoining,
+	"Leaving":    StateLeaving,
+	"Suspended":  StateSuspended,
+	"Shutdown":   StateShutdown,
+}
+
+// ParseNodeState parse the state string of chain info, StateUnknown if not recognized
+func ParseNodeState(state string) NodeState {
+	return nodeStates[state]
+}
+
+func (s NodeState) String() string {
+	switch s {
+	case StateBabbling:
+		return "Babbling"
+	case StateCatchingUp:
+		return "CatchingUp"
+	case StateJoining:
+		return "Joining"
+	case StateLeaving:
+		return "Leaving"
+	case StateSuspended:
+		return "Suspended"
+	case StateShutdown:
+		return "Shutdown"
+	}
+	return "Unknown"
+}
+
 type RawTxRes struct {
 	TxHash       string `mapstructure:"txHash"`
 	ContractAddr string `mapstructure:"contractAddr"`
@@ -77,11 +194,66 @@ type JsonReceipt struct {
 	GasUsed           uint64          `mapstructure:"gasUsed"`
 	CumulativeGasUsed uint64          `mapstructure:"cumulativeGasUsed"`
 	ContractAddress   common.Address  `mapstructure:"contractAddress"`
-	Logs              []*ethTypes.Log `mapstructure:"logs"`
+	Logs              []*ethTypes.Log `mapstructure:"-"` // Logs decoded from the "logs" of jsonReceipt
 	LogsBloom         ethTypes.Bloom  `mapstructure:"logsBloom"`
 	Status            uint64          `mapstructure:"status"`
 }
 
+// jsonReceipt the receipt response, the logs are encoded by ethTypes.Log
+// whose json keys differ from the field names, so they are decoded by jsonLog
+type jsonReceipt struct {
+	JsonReceipt `mapstructure:",squash"`
+	Logs        []*jsonLog `mapstructure:"logs"`
+}
+
+// jsonLog the json encoding of ethTypes.Log
+type jsonLog struct {
+	Address     common.Address `mapstructure:"address"`
+	Topics      []common.Hash  `mapstructure:"topics"`
+	Data        string         `mapstructure:"data"`
+	BlockNumber uint64         `mapstructure:"blockNumber"`
+	TxHash      common.Hash    `mapstructure:"transactionHash"`
+	TxIndex     uint64         `mapstructure:"transactionIndex"`
+	BlockHash   common.Hash    `mapstructure:"blockHash"`
+	Index       uint64         `mapstructure:"logIndex"`
+	Removed     bool           `mapstructure:"removed"`
+}
+
+func (r *jsonReceipt) receipt() (*JsonReceipt, error) {
+	receipt := r.JsonReceipt
+	if r.Logs == nil {
+		return &receipt, nil
+	}
+
+	receipt.Logs = make([]*ethTypes.Log, len(r.Logs))
+	for i, lg := range r.Logs {
+		data, err := decodeLogData(lg.Data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "log %d data", i)
+		}
+		receipt.Logs[i] = &ethTypes.Log{
+			Address:     lg.Address,
+			Topics:      lg.Topics,
+			Data:        data,
+			BlockNumber: lg.BlockNumber,
+			TxHash:      lg.TxHash,
+			TxIndex:     uint(lg.TxIndex),
+			BlockHash:   lg.BlockHash,
+			Index:       uint(lg.Index),
+			Removed:     lg.Removed,
+		}
+	}
+	return &receipt, nil
+}
+
+// decodeLogData ethTypes.Log encodes data as 0x prefixed hex, base64 is accepted otherwise
+func decodeLogData(data string) ([]byte, error) {
+	if strings.HasPrefix(data, "0x") {
+		return hexutil.Decode(data)
+	}
+	return base64.StdEncoding.DecodeString(data)
+}
+
 // SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
 type SendTxArgs struct {
 	From         common.Address           `json:"from"`
@@ -0,0 +1,174 @@
+package rpc
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxErrBodyLen the max length of response body kept in errors
+const maxErrBodyLen = 512
+
+// ErrNotFound the requested block, receipt or account does not exist (yet)
+// use errors.Is(err, ErrNotFound) to check it
+var ErrNotFound = errors.New("not found")
+
+var notFoundMessages = []string{
+	"not found",
+	"not exist",
+	"no such",
+}
+
+// NodeError the error message returned by node in the "Err" field of response
+type NodeError struct {
+	// StatusCode http status code of the response
+	StatusCode int
+	// Message the server error message
+	Message string
+}
+
+func (e *NodeError) Error() string {
+	return fmt.Sprintf("response err -> %s", e.Message)
+}
+
+// HTTPStatusError node responded a non 2xx http status without an error message,
+// e.g. a 502 html page from the gateway
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+	// Body the beginning of response body
+	Body []byte
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http status %s: %s", e.Status, e.Body)
+}
+
+// DecodeError the response can not be decoded
+type DecodeError struct {
+	// Body the beginning of response body
+	Body []byte
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode response: %v", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// NotFoundError the requested resource does not exist, errors.Is(err, ErrNotFound) is true
+type NotFoundError struct {
+	// Resource the kind of requested resource, e.g. block, receipt
+	Resource string
+	// Key the index or hash of requested resource
+	Key string
+	Err error
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found: %v", e.Resource, e.Key, e.Err)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// IsNotFound check whether err means the requested resource does not exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// asNotFound convert err to NotFoundError if node reports the resource does not exist
+func asNotFound(err error, resource, key string) error {
+	var nodeErr *NodeError
+	if errors.As(err, &nodeErr) {
+		msg := strings.ToLower(nodeErr.Message)
+		for _, s := range notFoundMessages {
+			if strings.Contains(msg, s) {
+				return &NotFoundError{Resource: resource, Key: key, Err: err}
+			}
+		}
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return &NotFoundError{Resource: resource, Key: key, Err: err}
+	}
+
+	return err
+}
+
+// unsentError the request failed before it was sent to node,
+// i.e. an interceptor rejected it or the connection could not be established
+type unsentError struct {
+	err error
+}
+
+func (e *unsentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unsentError) Unwrap() error {
+	return e.err
+}
+
+// sentError an earlier attempt of the request may have reached node, even if the last one was not sent
+type sentError struct {
+	err error
+}
+
+func (e *sentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sentError) Unwrap() error {
+	return e.err
+}
+
+// asUnsent mark err as unsent if the connection to node could not be established
+func asUnsent(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return &unsentError{err: err}
+	}
+	return err
+}
+
+// asSent mark err as possibly sent if sent is set and err is unsent
+func asSent(err error, sent bool) error {
+	if sent && isUnsent(err) {
+		return &sentError{err: err}
+	}
+	return err
+}
+
+// isUnsent check whether the request of err provably never reached node
+func isUnsent(err error) bool {
+	for err != nil {
+		switch err.(type) {
+		case *sentError:
+			return false
+		case *unsentError:
+			return true
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
+}
+
+func truncateBody(body []byte) []byte {
+	if len(body) > maxErrBodyLen {
+		return body[:maxErrBodyLen]
+	}
+	return body
+}
@@ -0,0 +1,58 @@
+package rpc_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bolaxytools/tool-sdk/rpc"
+)
+
+func TestClient_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/info":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>502 Bad Gateway</html>"))
+		case "/block/9":
+			w.Write([]byte(`{"Data":null,"Err":"Block 9 not found"}`))
+		case "/tx/0x01":
+			w.Write([]byte(`{"Data":null,"Err":""}`))
+		case "/account/" + testAddress:
+			w.Write([]byte(`{"Data":{"nonce":"oops"`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"Data":null,"Err":"internal error"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := rpc.Dial(server.URL)
+
+	_, err := c.FetchChainInfo()
+	var statusErr *rpc.HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("FetchChainInfo: unexpected error %v", err)
+	}
+
+	if _, err := c.FetchBlock(9); !rpc.IsNotFound(err) {
+		t.Fatalf("FetchBlock: have %v, want not found", err)
+	}
+
+	if _, err := c.FetchReceipt("0x01"); !errors.Is(err, rpc.ErrNotFound) {
+		t.Fatalf("FetchReceipt: have %v, want not found", err)
+	}
+
+	_, err = c.FetchAccount(testAddress)
+	var decodeErr *rpc.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("FetchAccount: have %v, want decode error", err)
+	}
+
+	_, err = c.FetchBlock(1)
+	var nodeErr *rpc.NodeError
+	if !errors.As(err, &nodeErr) || nodeErr.Message != "internal error" || rpc.IsNotFound(err) {
+		t.Fatalf("FetchBlock: unexpected error %v", err)
+	}
+}
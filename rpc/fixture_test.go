@@ -0,0 +1,182 @@
+package rpc_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bolaxy/common"
+	ethTypes "github.com/bolaxy/eth/types"
+
+	"github.com/bolaxytools/tool-sdk"
+	"github.com/bolaxytools/tool-sdk/rpc"
+	"github.com/bolaxytools/tool-sdk/rpc/rpctest"
+)
+
+// testdata/fixtures holds responses recorded from a real Bolaxy node, which pin its wire format.
+// go test -run TestClient_Fixtures -record -node http://host:port
+// records the chain info, the last block, its receipts and contract accounts from the node.
+//
+// testdata/synthetic holds hand-written responses in the format of the rpctest fake node,
+// they are not recordings of a real node and pin only the decoding of the client,
+// including the encodings seen from other node versions: hex []byte fields of block,
+// and "null" or empty to and contract addresses of receipt.
+var (
+	record     = flag.Bool("record", false, "record fixtures from the node instead of replaying them")
+	recordNode = flag.String("node", "", "the node to record fixtures from, required by -record")
+)
+
+const (
+	fixtureDir   = "testdata/fixtures"
+	syntheticDir = "testdata/synthetic"
+)
+
+func TestClient_Fixtures(t *testing.T) {
+	if *record && *recordNode == "" {
+		t.Skip("-node is not set, nothing to record")
+	}
+	if _, err := os.Stat(fixtureDir); !*record && os.IsNotExist(err) {
+		t.Skip("no fixtures recorded from a real node, run with -record -node")
+	}
+
+	client, err := rpctest.DialFixture(*recordNode, fixtureDir, *record)
+	if err != nil {
+		t.Fatalf("DialFixture: %v", err)
+	}
+	checkLastBlock(t, client)
+}
+
+func TestClient_SyntheticFixtures(t *testing.T) {
+	client, err := rpctest.DialFixture("", syntheticDir, false)
+	if err != nil {
+		t.Fatalf("DialFixture: %v", err)
+	}
+	checkLastBlock(t, client)
+
+	if _, err := client.FetchBlock(2); !rpc.IsNotFound(err) {
+		t.Fatalf("FetchBlock 2: have %v, want not found", err)
+	}
+
+	receipt, err := client.FetchReceipt("0x5a9e7bf3e9627764b308a0b4e6e875c1197153a9628d98af366bb72ba1bfce5e")
+	if err != nil {
+		t.Fatalf("FetchReceipt: %v", err)
+	}
+	if receipt.GasUsed != 53000 || receipt.CumulativeGasUsed != 74000 || len(receipt.Logs) != 2 {
+		t.Fatalf("unexpected receipt: gas %d, cumulative %d, %d logs", receipt.GasUsed, receipt.CumulativeGasUsed, len(receipt.Logs))
+	}
+	// log data is hex encoded by ethTypes.Log
+	if !bytes.Equal(receipt.Logs[0].Data, []byte{0x12, 0x34, 0x56, 0x78}) {
+		t.Fatalf("log data: have %x, want 12345678", receipt.Logs[0].Data)
+	}
+	// anonymous log with null topics and empty data
+	if len(receipt.Logs[1].Topics) != 0 || len(receipt.Logs[1].Data) != 0 {
+		t.Fatalf("anonymous log: topics %v, data %x", receipt.Logs[1].Topics, receipt.Logs[1].Data)
+	}
+
+	// some node versions encode the []byte fields of block in hex instead of base64
+	blk, err := client.FetchBlock(3)
+	if err != nil {
+		t.Fatalf("FetchBlock 3: %v", err)
+	}
+	if blk.Body.Index != 3 || blk.Body.RoundReceived != 4 || len(blk.Body.Transactions) != 1 {
+		t.Fatalf("unexpected block: index %d, round %d, %d txs", blk.Body.Index, blk.Body.RoundReceived, len(blk.Body.Transactions))
+	}
+	if want := common.FromHex("0xc89efdaa54c0f20c7adf612882df0950f5a951637e0307cdcb4c672f298b8bc6"); !bytes.Equal(blk.Body.StateHash, want) {
+		t.Fatalf("state hash: have %x, want %x", blk.Body.StateHash, want)
+	}
+	if want := common.FromHex("0x3d5a0b1c9e8f7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b"); !bytes.Equal(blk.Body.PeersHash, want) {
+		t.Fatalf("peers hash: have %x, want %x", blk.Body.PeersHash, want)
+	}
+	if len(blk.Signatures) != 1 {
+		t.Fatalf("signatures: %v", blk.Signatures)
+	}
+	for validator, sig := range blk.Signatures {
+		if !strings.HasPrefix(validator, "0x04A1B2") || sig != "1q2w3e4r5t6y7u8i9o0p|a1s2d3f4g5h6j7k8l9" {
+			t.Fatalf("signature: %s => %s", validator, sig)
+		}
+	}
+
+	// creation receipt with the string "null" as to address
+	receipt, err = client.FetchReceipt("0x9f2c1a4e6b8d0f1e3a5c7b9d2e4f6a8c0b1d3e5f7a9c2b4d6e8f0a1c3e5b7d9f")
+	if err != nil {
+		t.Fatalf("FetchReceipt: %v", err)
+	}
+	if receipt.To != nil {
+		t.Fatalf("to: have %s, want nil", receipt.To.String())
+	}
+	if want := common.HexToAddress("0x56d3bdb16a80da404890fb898d8fc827b3f92574"); receipt.ContractAddress != want {
+		t.Fatalf("contract address: have %s, want %s", receipt.ContractAddress.String(), want.String())
+	}
+	if receipt.From != common.HexToAddress("0x17f9ab565f346adb864f2683475fbeebccf52dbb") || receipt.GasUsed != 53000 || receipt.Status != 1 {
+		t.Fatalf("unexpected receipt: from %s, gas %d, status %d", receipt.From.String(), receipt.GasUsed, receipt.Status)
+	}
+
+	// transfer receipt with an empty contract address
+	receipt, err = client.FetchReceipt("0x4b7e2d9a1c3f5e8b0d2a4c6e8f1b3d5a7c9e0f2b4d6a8c1e3f5b7d9a0c2e4f6b")
+	if err != nil {
+		t.Fatalf("FetchReceipt: %v", err)
+	}
+	if want := common.HexToAddress("0xbf0c265f0d1b3df1229f34486b62fee1e99f0d10"); receipt.To == nil || *receipt.To != want {
+		t.Fatalf("to: have %v, want %s", receipt.To, want.String())
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		t.Fatalf("contract address: have %s, want zero", receipt.ContractAddress.String())
+	}
+	if receipt.GasUsed != 21000 || receipt.CumulativeGasUsed != 74000 {
+		t.Fatalf("unexpected receipt: gas %d, cumulative %d", receipt.GasUsed, receipt.CumulativeGasUsed)
+	}
+}
+
+// checkLastBlock fetch the last block of chain info, its transactions, receipts and created contracts
+func checkLastBlock(t *testing.T, client *rpc.Client) {
+	meta, err := client.FetchChainInfo()
+	if err != nil {
+		t.Fatalf("FetchChainInfo: %v", err)
+	}
+	last, err := meta.LastBlockIndex()
+	if err != nil || last < 0 || meta.State == "" {
+		t.Fatalf("unexpected chain info: %+v, %v", meta, err)
+	}
+
+	blk, err := client.FetchBlock(int(last))
+	if err != nil {
+		t.Fatalf("FetchBlock: %v", err)
+	}
+	txs, err := sdk.GetTransactionsFromBlkWithChainID(blk, client.ChainID())
+	if err != nil {
+		t.Fatalf("GetTransactionsFromBlk: %v", err)
+	}
+	if len(blk.Body.StateHash) != 32 {
+		t.Fatalf("unexpected block: state hash %x", blk.Body.StateHash)
+	}
+
+	for _, tx := range txs {
+		receipt, err := client.FetchReceipt(tx.Hash)
+		if err != nil {
+			t.Fatalf("FetchReceipt %s: %v", tx.Hash, err)
+		}
+		if receipt.TransactionHash != common.HexToHash(tx.Hash) || receipt.From != common.HexToAddress(tx.From) {
+			t.Fatalf("receipt of %s: unexpected hash %s or from %s", tx.Hash, receipt.TransactionHash.String(), receipt.From.String())
+		}
+
+		// contract creation has a null to address
+		if tx.To == "" {
+			if receipt.To != nil || receipt.ContractAddress == (common.Address{}) {
+				t.Fatalf("receipt of creation %s: to %v, contract address %s", tx.Hash, receipt.To, receipt.ContractAddress.String())
+			}
+			if ok, err := client.IsContract(receipt.ContractAddress.String()); err != nil || !ok {
+				t.Fatalf("IsContract %s: %v, %v", receipt.ContractAddress.String(), ok, err)
+			}
+		} else if receipt.To == nil || *receipt.To != common.HexToAddress(tx.To) {
+			t.Fatalf("receipt of %s: to %v, want %s", tx.Hash, receipt.To, tx.To)
+		}
+
+		for _, lg := range receipt.Logs {
+			if !ethTypes.BloomLookup(receipt.LogsBloom, lg.Address) {
+				t.Fatalf("receipt of %s: log address %s not in bloom", tx.Hash, lg.Address.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"net/http"
+	"time"
+)
+
+// Interceptor hooks of every http exchange of Client, nil hooks are skipped.
+// interceptors are called in the order they are added, each retry attempt is a new exchange.
+type Interceptor struct {
+	// BeforeRequest called before the request is sent, it can modify the request,
+	// e.g. add a request id or sign it, the body can be read again by req.GetBody.
+	// returning an error aborts the request with it, which is useful for fault injection
+	BeforeRequest func(req *http.Request) error
+	// AfterResponse called after the response body is read and before the status is checked,
+	// resp.Body is already consumed, body is the payload.
+	// returning an error fails the request with it
+	AfterResponse func(req *http.Request, resp *http.Response, body []byte, elapsed time.Duration) error
+	// OnError called when the exchange fails, by the transport, a non 2xx status or another hook
+	OnError func(req *http.Request, err error, elapsed time.Duration)
+}
+
+// WithInterceptor add interceptors to Client
+func WithInterceptor(interceptors ...*Interceptor) DialOpt {
+	return func(client *Client) {
+		client.interceptors = append(client.interceptors, interceptors...)
+	}
+}
+
+// LogInterceptor log every request, response and error by logf, e.g. log.Printf or t.Logf
+func LogInterceptor(logf func(format string, args ...interface{})) *Interceptor {
+	return &Interceptor{
+		BeforeRequest: func(req *http.Request) error {
+			logf("rpc request: %s %s", req.Method, req.URL)
+			return nil
+		},
+		AfterResponse: func(req *http.Request, resp *http.Response, body []byte, elapsed time.Duration) error {
+			logf("rpc response: %s %s -> %s in %v: %s", req.Method, req.URL, resp.Status, elapsed, truncateBody(body))
+			return nil
+		},
+		OnError: func(req *http.Request, err error, elapsed time.Duration) {
+			logf("rpc error: %s %s in %v: %v", req.Method, req.URL, elapsed, err)
+		},
+	}
+}
+
+func (c *Client) beforeRequest(req *http.Request) error {
+	for _, i := range c.interceptors {
+		if i.BeforeRequest != nil {
+			if err := i.BeforeRequest(req); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) afterResponse(req *http.Request, resp *http.Response, body []byte, elapsed time.Duration) error {
+	for _, i := range c.interceptors {
+		if i.AfterResponse != nil {
+			if err := i.AfterResponse(req, resp, body, elapsed); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) onError(req *http.Request, err error, elapsed time.Duration) {
+	for _, i := range c.interceptors {
+		if i.OnError != nil {
+			i.OnError(req, err, elapsed)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package rpc_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bolaxytools/tool-sdk/rpc"
+)
+
+func TestClient_Interceptor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Request-Id") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"Data":{"address":"%s","balance":0,"nonce":3,"bytecode":""},"Err":""}`, testAddress)
+	}))
+	defer server.Close()
+
+	var (
+		requests, responses, failures int32
+		fault                         int32
+		errFault                      = errors.New("injected fault")
+	)
+	c := rpc.Dial(server.URL,
+		rpc.WithRetry(&rpc.RetryPolicy{MaxAttempts: 2, Retryable: func(err error) bool { return errors.Is(err, errFault) }}),
+		rpc.WithInterceptor(&rpc.Interceptor{
+			BeforeRequest: func(req *http.Request) error {
+				n := atomic.AddInt32(&requests, 1)
+				req.Header.Set("X-Request-Id", fmt.Sprint(n))
+				if atomic.LoadInt32(&fault) > 0 {
+					return errFault
+				}
+				return nil
+			},
+			AfterResponse: func(req *http.Request, resp *http.Response, body []byte, elapsed time.Duration) error {
+				atomic.AddInt32(&responses, 1)
+				if !strings.Contains(string(body), `"nonce":3`) {
+					t.Errorf("unexpected body: %s", body)
+				}
+				return nil
+			},
+			OnError: func(req *http.Request, err error, elapsed time.Duration) {
+				atomic.AddInt32(&failures, 1)
+			},
+		}),
+	)
+
+	nonce, err := c.FetchNonce(testAddress)
+	if err != nil || nonce != 3 {
+		t.Fatalf("FetchNonce: %d, %v", nonce, err)
+	}
+	if requests != 1 || responses != 1 || failures != 0 {
+		t.Fatalf("requests %d, responses %d, failures %d", requests, responses, failures)
+	}
+
+	atomic.StoreInt32(&fault, 1)
+	if _, err := c.FetchNonce(testAddress); !errors.Is(err, errFault) {
+		t.Fatalf("FetchNonce: have %v, want injected fault", err)
+	}
+	// the fault is retried once, every attempt goes through the interceptor
+	if requests != 3 || responses != 1 || failures != 2 {
+		t.Fatalf("requests %d, responses %d, failures %d", requests, responses, failures)
+	}
+}
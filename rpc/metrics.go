@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bolaxytools/tool-sdk/metrics"
+)
+
+// WithMetrics record the requests, errors and latency of every http exchange by endpoint and path,
+// errors reported by node in the "Err" field of a 2xx response are not counted.
+// every request is counted once when it completes, including the ones aborted by an interceptor,
+// so the errors never outnumber the requests
+func WithMetrics(m metrics.Metrics) DialOpt {
+	return func(client *Client) {
+		client.interceptors = append(client.interceptors, metricsInterceptor(client, m))
+	}
+}
+
+type metricsKey struct{}
+
+// metricsState whether the request is counted, i.e. a response is received
+type metricsState struct {
+	counted bool
+}
+
+func metricsInterceptor(client *Client, m metrics.Metrics) *Interceptor {
+	labels := func(req *http.Request) metrics.Labels {
+		return metrics.Labels{"endpoint": client.host, "path": metricsPath(req.URL.Path)}
+	}
+	requests := func(req *http.Request) {
+		m.Counter("bolaxy_rpc_requests_total", "Total number of requests sent to node.", labels(req)).Inc()
+	}
+
+	return &Interceptor{
+		BeforeRequest: func(req *http.Request) error {
+			// the state is carried by the request, so it is dropped with the request
+			*req = *req.WithContext(context.WithValue(req.Context(), metricsKey{}, &metricsState{}))
+			return nil
+		},
+		AfterResponse: func(req *http.Request, resp *http.Response, body []byte, elapsed time.Duration) error {
+			if state, ok := req.Context().Value(metricsKey{}).(*metricsState); ok {
+				state.counted = true
+			}
+			requests(req)
+			m.Histogram("bolaxy_rpc_request_duration_seconds", "Latency of requests to node.", nil, labels(req)).Observe(elapsed.Seconds())
+			return nil
+		},
+		OnError: func(req *http.Request, err error, elapsed time.Duration) {
+			// the request failed without a response, or is aborted before the BeforeRequest of metrics
+			if state, ok := req.Context().Value(metricsKey{}).(*metricsState); !ok || !state.counted {
+				requests(req)
+			}
+			m.Counter("bolaxy_rpc_errors_total", "Total number of failed requests to node.", labels(req)).Inc()
+		},
+	}
+}
+
+// metricsPath the api of path without the key, e.g. /block/12 -> /block/
+func metricsPath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return path
+	}
+	if i := strings.Index(path[1:], "/"); i >= 0 {
+		return path[:i+2]
+	}
+	return path
+}
+
+// WithMonitorMetrics record the heights, lag and processed blocks of the monitor,
+// labeled by the name of the monitor
+func WithMonitorMetrics(m metrics.Metrics) MonitorOpt {
+	return func(monitor *blkMonitor) {
+		monitor.metrics = m
+	}
+}
+
+// WithMonitorName set the monitor label of the monitor metrics,
+// default is the number of the monitor in creation order, e.g. "1"
+func WithMonitorName(name string) MonitorOpt {
+	return func(monitor *blkMonitor) {
+		monitor.name = name
+	}
+}
+
+type monitorStats struct {
+	current   metrics.Gauge
+	target    metrics.Gauge
+	lag       metrics.Gauge
+	processed metrics.Counter
+	errors    metrics.Counter
+}
+
+func newMonitorStats(m metrics.Metrics, name string) *monitorStats {
+	m = metrics.Or(m)
+	labels := metrics.Labels{"monitor": name}
+	return &monitorStats{
+		current:   m.Gauge("bolaxy_monitor_current_height", "Index of the last block processed by the monitor.", labels),
+		target:    m.Gauge("bolaxy_monitor_target_height", "The last_block_index reported by node.", labels),
+		lag:       m.Gauge("bolaxy_monitor_lag_blocks", "Number of blocks the monitor is behind node.", labels),
+		processed: m.Counter("bolaxy_monitor_blocks_processed_total", "Total number of blocks processed by the monitor.", labels),
+		errors:    m.Counter("bolaxy_monitor_errors_total", "Total number of errors of the monitor.", labels),
+	}
+}
+
+// setHeights update the current and target heights and the lag between them
+func (s *monitorStats) setHeights(current, target uint64) {
+	s.current.Set(float64(current))
+	s.target.Set(float64(target))
+	if target > current {
+		s.lag.Set(float64(target - current))
+	} else {
+		s.lag.Set(0)
+	}
+}
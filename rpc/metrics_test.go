@@ -0,0 +1,132 @@
+package rpc_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bolaxy/common"
+
+	"github.com/bolaxytools/tool-sdk"
+	"github.com/bolaxytools/tool-sdk/metrics"
+	"github.com/bolaxytools/tool-sdk/rpc"
+)
+
+func metricsText(t *testing.T, r *metrics.Registry) string {
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	return buf.String()
+}
+
+func waitMetric(t *testing.T, r *metrics.Registry, line string) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !strings.Contains(metricsText(t, r), line) {
+		if time.Now().After(deadline) {
+			t.Fatalf("metric %q not found in:\n%s", line, metricsText(t, r))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClient_Metrics(t *testing.T) {
+	node, _, _ := newTestNode(t)
+	registry := metrics.NewRegistry()
+	// an interceptor before the metrics aborts the requests of /tx/
+	abort := &rpc.Interceptor{BeforeRequest: func(req *http.Request) error {
+		if strings.HasPrefix(req.URL.Path, "/tx/") {
+			return errors.New("aborted")
+		}
+		return nil
+	}}
+	client := node.Client(rpc.WithInterceptor(abort), rpc.WithMetrics(registry), rpc.WithRetry(nil))
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.FetchNonce(testAddress); err != nil {
+			t.Fatalf("FetchNonce: %v", err)
+		}
+	}
+	// the fake node reports a missing block in a 2xx response, it is a request but not an error
+	if _, err := client.FetchBlock(1000); err == nil {
+		t.Fatalf("FetchBlock: expected error")
+	}
+	if _, err := client.FetchReceipt("0x01"); err == nil {
+		t.Fatalf("FetchReceipt: expected error")
+	}
+	node.Close()
+	if _, err := client.FetchBlock(0); err == nil {
+		t.Fatalf("FetchBlock: expected error")
+	}
+
+	text := metricsText(t, registry)
+	for _, line := range []string{
+		fmt.Sprintf(`bolaxy_rpc_requests_total{endpoint="%s",path="/account/"} 2`, node.URL()),
+		fmt.Sprintf(`bolaxy_rpc_request_duration_seconds_count{endpoint="%s",path="/account/"} 2`, node.URL()),
+		fmt.Sprintf(`bolaxy_rpc_requests_total{endpoint="%s",path="/block/"} 2`, node.URL()),
+		fmt.Sprintf(`bolaxy_rpc_errors_total{endpoint="%s",path="/block/"} 1`, node.URL()),
+		fmt.Sprintf(`bolaxy_rpc_requests_total{endpoint="%s",path="/tx/"} 1`, node.URL()),
+		fmt.Sprintf(`bolaxy_rpc_errors_total{endpoint="%s",path="/tx/"} 1`, node.URL()),
+	} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("metric %q not found in:\n%s", line, text)
+		}
+	}
+}
+
+func TestClient_MetricsStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	client := rpc.Dial(server.URL, rpc.WithMetrics(registry), rpc.WithRetry(nil))
+	if _, err := client.FetchChainInfo(); err == nil {
+		t.Fatalf("FetchChainInfo: expected error")
+	}
+
+	// the response is counted once, though both AfterResponse and OnError see it
+	text := metricsText(t, registry)
+	for _, line := range []string{
+		fmt.Sprintf(`bolaxy_rpc_requests_total{endpoint="%s",path="/info"} 1`, server.URL),
+		fmt.Sprintf(`bolaxy_rpc_errors_total{endpoint="%s",path="/info"} 1`, server.URL),
+	} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("metric %q not found in:\n%s", line, text)
+		}
+	}
+}
+
+func TestBlkMonitor_Metrics(t *testing.T) {
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	for i := 0; i < 2; i++ {
+		transfer(t, client, key, common.HexToAddress(testAddress), big.NewInt(1))
+	}
+
+	registry := metrics.NewRegistry()
+	emitter := sdk.NewEventEmitter(16, sdk.WithEmitterMetrics(registry))
+	monitor := rpc.NewBlkMonitor(emitter, client, rpc.WithPeriod(10*time.Millisecond),
+		rpc.WithMonitorMetrics(registry), rpc.WithMonitorName("head"))
+	monitor.Start()
+	defer monitor.Stop()
+	// a second monitor on the same registry does not overwrite the metrics of the first one
+	behind := rpc.NewBlkMonitor(sdk.NewEventEmitter(16), client, rpc.WithPeriod(10*time.Millisecond),
+		rpc.WithMonitorMetrics(registry), rpc.WithMonitorName("behind"), rpc.WithStartIndex(2))
+	behind.Start()
+	defer behind.Stop()
+
+	waitMetric(t, registry, `bolaxy_monitor_blocks_processed_total{monitor="head"} 2`)
+	waitMetric(t, registry, `bolaxy_monitor_current_height{monitor="head"} 2`)
+	waitMetric(t, registry, `bolaxy_monitor_lag_blocks{monitor="head"} 0`)
+	waitMetric(t, registry, `bolaxy_monitor_blocks_processed_total{monitor="behind"} 1`)
+	waitMetric(t, registry, "bolaxy_emitter_events_total 2")
+}
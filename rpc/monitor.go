@@ -1,23 +1,77 @@
 package rpc
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"math/big"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/core/types"
+	"github.com/pkg/errors"
+
 	"github.com/bolaxytools/tool-sdk"
+	"github.com/bolaxytools/tool-sdk/metrics"
 )
 
 var (
-	defaultPeriod = 3 * time.Second
+	defaultPeriod     = 3 * time.Second
+	defaultMaxBackoff = time.Minute
+	defaultBatchSize  = 100
+
+	// monitorSeq the number of monitors created, the default name of a monitor
+	monitorSeq uint64
 )
 
 // Monitor bolaxy block scanner
 type Monitor interface {
-	// Start start monitor
+	// Start start monitor, it is a no-op after Stop
 	Start()
-	// Stop stop monitor
+	// Stop stop monitor, a stopped monitor can not be started again
 	Stop()
+	// Health the current status of monitor
+	Health() MonitorHealth
+	// Err the error of the last scan, nil if it succeeded
+	Err() error
+}
+
+// MonitorHealth the status of monitor
+type MonitorHealth struct {
+	// Running the monitor is started and not stopped
+	Running bool
+	// Healthy the last scan succeeded, false until the first scan finishes
+	Healthy bool
+	// Next the index of the next block to process
+	Next uint64
+	// Height the last_block_index reported by node
+	Height uint64
+	// Failures the number of consecutive failed scans
+	Failures int
+	// LastErr the error of the last failed scan
+	LastErr error
+	// LastSuccess the time of the last successful scan
+	LastSuccess time.Time
+}
+
+// MonitorError the error of a scan, the block at Index will be processed again
+type MonitorError struct {
+	// Index the block being processed
+	Index uint64
+	// Stage the failed step, e.g. chainInfo, block, txs, receipt, checkpoint
+	Stage string
+	Err   error
+}
+
+func (e *MonitorError) Error() string {
+	return fmt.Sprintf("blkMonitor %s of block %d: %v", e.Stage, e.Index, e.Err)
+}
+
+func (e *MonitorError) Unwrap() error {
+	return e.Err
 }
 
 // MonitorOpt bolaxy block scanner settings
@@ -37,14 +91,53 @@ func WithStartIndex(startIndex uint64) MonitorOpt {
 	}
 }
 
+// WithErrorHandler set the callback of scan errors, it is called in the scanning goroutine
+// and should not block. the failed block is retried after a backoff.
+// a transaction with an invalid value is also reported, its events are still emitted with a nil Value
+func WithErrorHandler(fn func(err error)) MonitorOpt {
+	return func(monitor *blkMonitor) {
+		monitor.onError = fn
+	}
+}
+
+// WithBackoff set the wait time before retrying a failed scan, it begins at initial,
+// doubles after each failure up to max. default is the period up to 1 minute
+func WithBackoff(initial, max time.Duration) MonitorOpt {
+	return func(monitor *blkMonitor) {
+		monitor.backoff = &RetryPolicy{InitialBackoff: initial, MaxBackoff: max, Multiplier: 2, Jitter: 0.2}
+	}
+}
+
+// WithBatchSize set the max number of blocks fetched in a row when the monitor is behind, default is 100
+// the chain height is refreshed after each batch
+func WithBatchSize(size int) MonitorOpt {
+	return func(monitor *blkMonitor) {
+		monitor.batchSize = size
+	}
+}
+
+// WithScanConcurrency set the max number of blocks and receipts fetched at the same time
+// when the monitor is behind, default is 8. events are always emitted in block order
+func WithScanConcurrency(concurrency int) MonitorOpt {
+	return func(monitor *blkMonitor) {
+		monitor.concurrency = concurrency
+	}
+}
+
 // NewBlkMonitor new block scan monitoring program
 // The block scanner will use the Emitter to notify
 // the transaction hash in the block and the Log details in the Receipt.
 // Transaction`s event type is hex of txhash sdk.GenHashType(receipt.TransactionHash)
-// Event`s event type is hexutil.Encode(crypto.Keccak256(contractAddr.Bytes(), eventSig.Bytes()))
+// Event`s event type is hexutil.Encode(crypto.Keccak256(contractAddr.Bytes(), eventSig.Bytes())),
+// contractAddr is the address of the contract emitting the log, also for logs of contract creations
+// and internal calls, logs without topics use sdk.GenAnonymousLogType(contractAddr)
 // if event result returned and result.Success == true then has been officially written into the block
-func NewBlkMonitor(e *sdk.Emitter, client *Client, opts ...MonitorOpt) Monitor {
-	monitor := &blkMonitor{emitter: e, http: client, quit: make(chan struct{}, 1)}
+// the monitor keeps running on errors, a failed block is retried with backoff until it succeeds,
+// the events of a block are emitted after all its receipts are fetched.
+// if the monitor is behind, it drains all blocks to last_block_index in batches
+// without waiting, then scans every period once it is at the head.
+func NewBlkMonitor(e *sdk.Emitter, client API, opts ...MonitorOpt) Monitor {
+	monitor := &blkMonitor{emitter: e, http: client, quit: make(chan struct{})}
 	for _, opt := range opts {
 		opt(monitor)
 	}
@@ -52,121 +145,407 @@ func NewBlkMonitor(e *sdk.Emitter, client *Client, opts ...MonitorOpt) Monitor {
 	if monitor.period <= 0 {
 		monitor.period = defaultPeriod
 	}
+	if monitor.batchSize <= 0 {
+		monitor.batchSize = defaultBatchSize
+	}
+	if monitor.concurrency <= 0 {
+		monitor.concurrency = defaultBlocksConcurrency
+	}
+	if monitor.backoff == nil {
+		monitor.backoff = &RetryPolicy{InitialBackoff: monitor.period, MaxBackoff: defaultMaxBackoff, Multiplier: 2, Jitter: 0.2}
+	}
+	if monitor.name == "" {
+		monitor.name = strconv.FormatUint(atomic.AddUint64(&monitorSeq, 1), 10)
+	}
+	monitor.stats = newMonitorStats(monitor.metrics, monitor.name)
 
 	return monitor
 }
 
 type blkMonitor struct {
-	http       *Client
-	emitter    *sdk.Emitter
-	period     time.Duration
-	quit       chan struct{}
-	startIndex uint64
+	http               API
+	emitter            *sdk.Emitter
+	period             time.Duration
+	quit               chan struct{}
+	startIndex         uint64
+	name               string
+	metrics            metrics.Metrics
+	stats              *monitorStats
+	onError            func(err error)
+	backoff            *RetryPolicy
+	batchSize          int
+	concurrency        int
+	checkpointer       Checkpointer
+	checkpointFallback bool
+	startOnce          sync.Once
+	stopOnce           sync.Once
+
+	mu      sync.RWMutex
+	stopped bool
+	health  MonitorHealth
 }
 
-// Start start monitor
+// Start start monitor, it is a no-op after Stop
 func (m *blkMonitor) Start() {
-	ticker := time.NewTicker(m.period)
+	m.startOnce.Do(func() {
+		next := m.startIndex
+		if next == 0 {
+			next = 1
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.stopped {
+			return
+		}
+		m.health.Running = true
+		m.health.Next = next
 
-	// 检查
+		go m.run(next)
+	})
+}
+
+// Stop stop monitor
+func (m *blkMonitor) Stop() {
+	m.stopOnce.Do(func() {
+		m.mu.Lock()
+		m.stopped = true
+		m.health.Running = false
+		m.mu.Unlock()
+
+		close(m.quit)
+	})
+}
+
+// Health the current status of monitor
+func (m *blkMonitor) Health() MonitorHealth {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.health
+}
+
+// Err the error of the last scan, nil if it succeeded
+func (m *blkMonitor) Err() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.health.Healthy {
+		return nil
+	}
+	return m.health.LastErr
+}
+
+func (m *blkMonitor) run(next uint64) {
+	timer := time.NewTimer(m.period)
+	defer timer.Stop()
+
+	// ctx is canceled by Stop, so requests in flight are interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
-		firstStarting := true
-		var next uint64
-		for {
-			select {
-			case <-ticker.C:
-				if firstStarting && m.startIndex > 0 {
-					log.Printf("blkMonitor first starting, and start index: %d\n", m.startIndex)
-					next = m.startIndex
-				} else {
-					log.Printf("blkMonitor loop:\n")
-					info, err := m.http.FetchChainInfo()
-					if err != nil {
-						log.Printf("blkMonitor fetch chain info failed. %v\n", err)
-						return
-					}
-					x, _ := strconv.ParseInt(info.BlockHeight, 10, 64)
-
-					next += 1
-					if uint64(x) < next {
-						next = uint64(x)
-						log.Printf("blkMonitor current blk height: %d skip\n", next)
-						continue
-					}
-				}
-
-				firstStarting = false
-				blk, err := m.http.FetchBlock(int(next))
-				if err != nil {
-					log.Printf("blkMonitor fetch blk failed. %v\n", err)
-					return
-				}
-				txs, err := sdk.GetTransactionsFromBlk(blk)
-				if err != nil {
-					log.Printf("blkMonitor get txs failed. %v\n", err)
-					return
-				}
-
-				for _, tx := range txs {
-					receipt, err := m.http.FetchReceipt(tx.Hash)
-					if err != nil {
-						log.Printf("blkMonitor fetch receipt failed. %v\n", err)
-						return
-					}
-
-					success := true
-					if receipt.Status == 0 {
-						success = false
-					}
-
-					var evt *sdk.Event
-					res := &sdk.Result{
-						Success:         success,
-						ContractAddress: nil,
-						IsLog:           false,
-						Data:            nil,
-						Topics:          nil,
-					}
-
-					evtTyp := sdk.GenHashType(receipt.TransactionHash)
-					if receipt.To == nil {
-						log.Printf("blkMonitor fire contract creation event, event type: %s, %s (%v)\n", evtTyp, receipt.ContractAddress.String(), success)
-						res.ContractAddress = &receipt.ContractAddress
-						evt = sdk.NewEvent(evtTyp, res)
-					} else {
-						log.Printf("blkMonitor fire tx event, %s (%v)\n", tx.Hash, success)
-						log.Printf("blkMonitor ->%s, %s \n", tx.Hash, receipt.TransactionHash.String())
-						evt = sdk.NewEvent(evtTyp, res)
-					}
-					m.emitter.Emit(evt)
-
-					if len(receipt.Logs) > 0 && receipt.To != nil {
-						for _, lg := range receipt.Logs {
-							k := sdk.GenLogType(*receipt.To, lg.Topics[0])
-							logRes := &sdk.Result{
-								Success:         success,
-								ContractAddress: nil,
-								IsLog:           true,
-								Data:            lg.Data,
-								Topics:          lg.Topics,
-							}
-
-							log.Printf("blkMonitor fire log event, %s\n", k)
-							e := sdk.NewEvent(k, logRes)
-							m.emitter.Emit(e)
-						}
-					}
-				}
-
-			case <-m.quit:
-				ticker.Stop()
+		<-m.quit
+		cancel()
+	}()
+
+	failures := 0
+	loaded := m.checkpointer == nil
+	for {
+		select {
+		case <-timer.C:
+		case <-m.quit:
+			return
+		}
+
+		var err error
+		if !loaded {
+			next, err = m.loadCheckpoint(next)
+			loaded = err == nil
+		}
+		if err == nil {
+			next, err = m.scan(ctx, next)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		wait := m.period
+		if err != nil {
+			wait = m.backoff.backoff(failures)
+			failures++
+			log.Printf("blkMonitor scan failed, retry in %v. %v\n", wait, err)
+			m.setFailure(err, failures)
+			m.report(err)
+		} else {
+			failures = 0
+			m.setSuccess()
+		}
+
+		timer.Reset(wait)
+	}
+}
+
+// report count err and pass it to the error handler
+func (m *blkMonitor) report(err error) {
+	m.stats.errors.Inc()
+	if m.onError != nil {
+		m.onError(err)
+	}
+}
+
+// loadCheckpoint return the block after the checkpoint, or next if there is no checkpoint
+func (m *blkMonitor) loadCheckpoint(next uint64) (uint64, error) {
+	index, ok, err := m.checkpointer.Load()
+	if errors.Is(err, ErrInvalidCheckpoint) && m.checkpointFallback {
+		// the events from the start index are delivered again
+		log.Printf("blkMonitor ignore invalid checkpoint, start from %d. %v\n", next, err)
+		m.report(&MonitorError{Index: next, Stage: "checkpoint", Err: err})
+		return next, nil
+	}
+	if err != nil {
+		return next, &MonitorError{Index: next, Stage: "checkpoint", Err: err}
+	}
+	if !ok {
+		return next, nil
+	}
+
+	log.Printf("blkMonitor resume from checkpoint: %d\n", index)
+	m.setNext(index + 1)
+	return index + 1, nil
+}
+
+// scan process the blocks from next to the chain height in batches,
+// return the index of the next block to process.
+// next is not moved past a block which is not fully processed
+func (m *blkMonitor) scan(ctx context.Context, next uint64) (uint64, error) {
+	for ctx.Err() == nil {
+		info, err := m.http.FetchChainInfoContext(ctx)
+		if err != nil {
+			return next, &MonitorError{Index: next, Stage: "chainInfo", Err: err}
+		}
+		last, err := info.LastBlockIndex()
+		if err != nil {
+			return next, &MonitorError{Index: next, Stage: "chainInfo", Err: err}
+		}
+		if last < 0 {
+			// no block yet
+			return next, nil
+		}
+		target := uint64(last)
+		m.setHeight(target)
+
+		if target < next {
+			log.Printf("blkMonitor current blk height: %d skip\n", target)
+			m.stats.setHeights(next-1, target)
+			return next, nil
+		}
+
+		to := next + uint64(m.batchSize) - 1
+		if to > target {
+			to = target
+		}
+		if next, err = m.batch(ctx, next, to, target); err != nil {
+			return next, err
+		}
+	}
+	return next, nil
+}
+
+// batch process the blocks from next to to, return the index of the next block to process.
+// the checkpoint is saved once at the end of the batch, or when it fails after some blocks are processed
+func (m *blkMonitor) batch(ctx context.Context, next, to, target uint64) (uint64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := next
+	blocks, errc := streamBlocks(ctx, m.http, int(next), int(to), WithConcurrency(m.concurrency), withHeight(int(target)))
+	for blk := range blocks {
+		if err := m.process(ctx, next, blk); err != nil {
+			return m.saveCheckpoint(start, next, err)
+		}
+
+		m.stats.processed.Inc()
+		m.stats.setHeights(next, target)
+		next++
+		m.setNext(next)
+	}
+
+	if err := <-errc; err != nil {
+		return m.saveCheckpoint(start, next, &MonitorError{Index: next, Stage: "block", Err: err})
+	}
+	return m.saveCheckpoint(start, next, nil)
+}
+
+// saveCheckpoint save the last processed block if any block from start is processed, return next and err,
+// or the error of saving if err is nil
+func (m *blkMonitor) saveCheckpoint(start, next uint64, err error) (uint64, error) {
+	if m.checkpointer == nil || next == start {
+		return next, err
+	}
+
+	if saveErr := m.checkpointer.Save(next - 1); saveErr != nil && err == nil {
+		err = &MonitorError{Index: next - 1, Stage: "checkpoint", Err: saveErr}
+	}
+	return next, err
+}
+
+// process fetch all receipts of block index, then emit the events
+func (m *blkMonitor) process(ctx context.Context, index uint64, blk *types.Block) error {
+	txs, err := sdk.GetTransactionsFromBlkWithChainID(blk, m.http.ChainID())
+	if err != nil {
+		return &MonitorError{Index: index, Stage: "txs", Err: err}
+	}
+
+	receipts, err := m.fetchReceipts(ctx, txs)
+	if err != nil {
+		return &MonitorError{Index: index, Stage: "receipt", Err: err}
+	}
+
+	for i, receipt := range receipts {
+		m.emit(blk, txs[i], receipt)
+	}
+	return nil
+}
+
+// fetchReceipts fetch the receipts of txs concurrently, in the order of txs
+func (m *blkMonitor) fetchReceipts(ctx context.Context, txs []*sdk.Transaction) ([]*JsonReceipt, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		receipts = make([]*JsonReceipt, len(txs))
+		sem      = make(chan struct{}, m.concurrency)
+	)
+	for i, tx := range txs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, hash string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			receipt, err := m.http.FetchReceiptContext(ctx, hash)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
 				return
 			}
+			receipts[i] = receipt
+		}(i, tx.Hash)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return receipts, nil
+}
+
+// emit fire the tx event and the log events of a transaction,
+// each event carries the block, transaction and receipt it comes from
+func (m *blkMonitor) emit(blk *types.Block, tx *sdk.Transaction, receipt *JsonReceipt) {
+	success := true
+	if receipt.Status == 0 {
+		success = false
+	}
+
+	// Value is decimal string, 0x prefixed hex is also accepted.
+	// an invalid value is reported and left nil in the events
+	value, ok := new(big.Int).SetString(tx.Value, 0)
+	if !ok {
+		value = nil
+		m.report(&MonitorError{Index: uint64(blk.Body.Index), Stage: "value", Err: fmt.Errorf("invalid value %q of tx %s", tx.Value, tx.Hash)})
+	}
+
+	// every event gets its own copies, so subscribers can not affect each other
+	newResult := func() *sdk.Result {
+		res := &sdk.Result{
+			Success:       success,
+			BlockIndex:    uint64(blk.Body.Index),
+			RoundReceived: uint64(blk.Body.RoundReceived),
+			TxHash:        receipt.TransactionHash,
+			From:          receipt.From,
+			GasUsed:       receipt.GasUsed,
 		}
-	}()
+		if receipt.To != nil {
+			to := *receipt.To
+			res.To = &to
+		}
+		if value != nil {
+			res.Value = new(big.Int).Set(value)
+		}
+		return res
+	}
+
+	var evt *sdk.Event
+	res := newResult()
+
+	evtTyp := sdk.GenHashType(receipt.TransactionHash)
+	if receipt.To == nil {
+		log.Printf("blkMonitor fire contract creation event, event type: %s, %s (%v)\n", evtTyp, receipt.ContractAddress.String(), success)
+		res.ContractAddress = &receipt.ContractAddress
+		evt = sdk.NewEvent(evtTyp, res)
+	} else {
+		log.Printf("blkMonitor fire tx event, %s (%v)\n", receipt.TransactionHash.String(), success)
+		evt = sdk.NewEvent(evtTyp, res)
+	}
+	m.emitter.Emit(evt)
+
+	for _, lg := range receipt.Logs {
+		// anonymous logs have no event signature
+		var sig common.Hash
+		if len(lg.Topics) > 0 {
+			sig = lg.Topics[0]
+		}
+
+		k := sdk.GenLogType(lg.Address, sig)
+		logRes := newResult()
+		logRes.IsLog = true
+		logRes.Data = lg.Data
+		logRes.Topics = lg.Topics
+		logRes.LogIndex = lg.Index
+		logRes.LogAddress = lg.Address
+
+		log.Printf("blkMonitor fire log event, %s\n", k)
+		e := sdk.NewEvent(k, logRes)
+		m.emitter.Emit(e)
+	}
 }
 
-// Stop stop monitor
-func (m *blkMonitor) Stop() {
-	close(m.quit)
+func (m *blkMonitor) setHeight(height uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.health.Height = height
+}
+
+func (m *blkMonitor) setNext(next uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.health.Next = next
+}
+
+func (m *blkMonitor) setSuccess() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.health.Healthy = true
+	m.health.Failures = 0
+	m.health.LastSuccess = time.Now()
+}
+
+func (m *blkMonitor) setFailure(err error, failures int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.health.Healthy = false
+	m.health.Failures = failures
+	m.health.LastErr = err
 }
@@ -0,0 +1,367 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/crypto"
+	ethTypes "github.com/bolaxy/eth/types"
+
+	"github.com/bolaxytools/tool-sdk"
+	"github.com/bolaxytools/tool-sdk/rpc"
+	"github.com/bolaxytools/tool-sdk/rpc/rpctest"
+)
+
+// collectTxEvents subscribe the tx events of hashes, return the received hashes in order
+func collectTxEvents(emitter *sdk.Emitter, hashes []common.Hash) (func() []common.Hash, sdk.Cancel) {
+	var (
+		mu       sync.Mutex
+		received []common.Hash
+	)
+	types := make([]sdk.Type, 0, len(hashes))
+	for _, hash := range hashes {
+		types = append(types, sdk.GenHashType(hash))
+	}
+	cancel := emitter.On(func(e *sdk.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, common.HexToHash(string(e.GetType())))
+	}, types...)
+
+	return func() []common.Hash {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]common.Hash(nil), received...)
+	}, cancel
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBlkMonitor_Resilient(t *testing.T) {
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	var hashes []common.Hash
+	for i := 0; i < 3; i++ {
+		hashes = append(hashes, transfer(t, client, key, common.HexToAddress(testAddress), big.NewInt(1)).TxHash)
+	}
+
+	// the receipt api is down until 3 errors are reported
+	var down int32 = 1
+	errFault := errors.New("receipt api down")
+	faulty := node.Client(rpc.WithRetry(nil), rpc.WithInterceptor(&rpc.Interceptor{
+		BeforeRequest: func(req *http.Request) error {
+			if atomic.LoadInt32(&down) == 1 && strings.HasPrefix(req.URL.Path, "/tx/") {
+				return errFault
+			}
+			return nil
+		},
+	}))
+
+	var reported []error
+	var mu sync.Mutex
+	emitter := sdk.NewEventEmitter(16)
+	monitor := rpc.NewBlkMonitor(emitter, faulty,
+		rpc.WithPeriod(5*time.Millisecond),
+		rpc.WithBackoff(time.Millisecond, 5*time.Millisecond),
+		rpc.WithErrorHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, err)
+			if len(reported) == 3 {
+				atomic.StoreInt32(&down, 0)
+			}
+		}),
+	)
+
+	received, cancel := collectTxEvents(emitter, hashes)
+	defer cancel()
+
+	monitor.Start()
+	defer monitor.Stop()
+
+	waitFor(t, "errors", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reported) >= 3
+	})
+
+	mu.Lock()
+	var monitorErr *rpc.MonitorError
+	if !errors.As(reported[0], &monitorErr) || monitorErr.Index != 1 || monitorErr.Stage != "receipt" || !errors.Is(reported[0], errFault) {
+		t.Fatalf("unexpected error: %v", reported[0])
+	}
+	mu.Unlock()
+
+	waitFor(t, "events", func() bool { return len(received()) == len(hashes) })
+	waitFor(t, "health", func() bool { health := monitor.Health(); return health.Next == 4 && health.Healthy })
+
+	// every tx is delivered once and in order after the outage
+	for i, hash := range received() {
+		if hash != hashes[i] {
+			t.Fatalf("event %d: have %s, want %s", i, hash.String(), hashes[i].String())
+		}
+	}
+
+	health := monitor.Health()
+	if !health.Running || !health.Healthy || health.Failures != 0 || health.Height != 3 || monitor.Err() != nil {
+		t.Fatalf("unexpected health: %+v, %v", health, monitor.Err())
+	}
+
+	monitor.Stop()
+	if monitor.Health().Running {
+		t.Fatalf("monitor is running after Stop")
+	}
+}
+
+func TestBlkMonitor_StartStop(t *testing.T) {
+	node, client, _ := newTestNode(t)
+	defer node.Close()
+
+	// no scan has finished yet
+	monitor := rpc.NewBlkMonitor(sdk.NewEventEmitter(16), client, rpc.WithPeriod(time.Hour))
+	monitor.Start()
+	if health := monitor.Health(); !health.Running || health.Healthy {
+		t.Fatalf("health before the first scan: %+v", health)
+	}
+	monitor.Stop()
+
+	// a stopped monitor is not started again
+	monitor = rpc.NewBlkMonitor(sdk.NewEventEmitter(16), client, rpc.WithPeriod(time.Millisecond))
+	monitor.Stop()
+	monitor.Start()
+	time.Sleep(20 * time.Millisecond)
+	if health := monitor.Health(); health.Running || health.Healthy || !health.LastSuccess.IsZero() {
+		t.Fatalf("health of monitor started after Stop: %+v", health)
+	}
+}
+
+func TestBlkMonitor_CatchUp(t *testing.T) {
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	for i := 0; i < 250; i++ {
+		node.AddBlock()
+	}
+	var hashes []common.Hash
+	for i := 0; i < 5; i++ {
+		hashes = append(hashes, transfer(t, client, key, common.HexToAddress(testAddress), big.NewInt(1)).TxHash)
+	}
+
+	emitter := sdk.NewEventEmitter(16)
+	received, cancel := collectTxEvents(emitter, hashes)
+	defer cancel()
+
+	var infos int32
+	counting := node.Client(rpc.WithInterceptor(&rpc.Interceptor{BeforeRequest: func(req *http.Request) error {
+		if req.URL.Path == "/info" {
+			atomic.AddInt32(&infos, 1)
+		}
+		return nil
+	}}))
+
+	// one block per period would take 255 * 100ms
+	monitor := rpc.NewBlkMonitor(emitter, counting,
+		rpc.WithPeriod(100*time.Millisecond),
+		rpc.WithBatchSize(30),
+		rpc.WithScanConcurrency(4),
+	)
+	start := time.Now()
+	monitor.Start()
+	defer monitor.Stop()
+
+	waitFor(t, "catch up", func() bool { return monitor.Health().Next == 256 })
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("catch up took %v", elapsed)
+	}
+	// the chain info is fetched once per batch of 30 blocks, and once more at the head
+	if n := atomic.LoadInt32(&infos); n > 10 {
+		t.Fatalf("chain info fetched %d times, want at most 10", n)
+	}
+
+	waitFor(t, "events", func() bool { return len(received()) == len(hashes) })
+	for i, hash := range received() {
+		if hash != hashes[i] {
+			t.Fatalf("event %d: have %s, want %s", i, hash.String(), hashes[i].String())
+		}
+	}
+
+	// back to polling at the head
+	transfer(t, client, key, common.HexToAddress(testAddress), big.NewInt(1))
+	waitFor(t, "new block", func() bool { return monitor.Health().Next == 257 })
+	if monitor.Health().Height != 256 || monitor.Err() != nil {
+		t.Fatalf("unexpected health: %+v", monitor.Health())
+	}
+}
+
+func TestBlkMonitor_LogEvents(t *testing.T) {
+	var (
+		outer = common.HexToAddress(testAddress)
+		inner = common.HexToAddress("0x599d7abdb0a289f85aaca706b55d1b96cc07f348")
+		topic = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	)
+	node, client, key := newTestNode(t, rpctest.WithExecutor(func(from common.Address, tx *ethTypes.Transaction) *rpctest.Execution {
+		// the called contract calls inner, the created contract emits logs in its constructor
+		emitter := inner
+		if tx.To() == nil {
+			emitter = crypto.CreateAddress(from, tx.Nonce())
+		}
+		return &rpctest.Execution{
+			Status:  ethTypes.ReceiptStatusSuccessful,
+			GasUsed: 30000,
+			Logs: []*ethTypes.Log{
+				{Address: emitter, Topics: []common.Hash{topic}, Data: []byte{1}},
+				{Address: emitter, Data: []byte{2}},
+			},
+		}
+	}))
+	defer node.Close()
+
+	transfer(t, client, key, outer, big.NewInt(1))
+	created, err := client.SendTransaction(context.Background(), key, &rpc.SendTxArgs{Data: "0x6001600055"})
+	if err != nil {
+		t.Fatalf("SendTransaction: %v", err)
+	}
+
+	want := map[sdk.Type]string{
+		sdk.GenLogType(inner, topic):                      "inner",
+		sdk.GenAnonymousLogType(inner):                    "inner anonymous",
+		sdk.GenLogType(*created.ContractAddress, topic):   "created",
+		sdk.GenAnonymousLogType(*created.ContractAddress): "created anonymous",
+	}
+	var (
+		mu       sync.Mutex
+		received = make(map[sdk.Type][]byte)
+	)
+	emitter := sdk.NewEventEmitter(16)
+	types := make([]sdk.Type, 0, len(want))
+	for typ := range want {
+		types = append(types, typ)
+	}
+	// the outer contract emits nothing
+	types = append(types, sdk.GenLogType(outer, topic))
+	cancel := emitter.On(func(e *sdk.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[e.GetType()] = e.GetValue().(*sdk.Result).Data
+	}, types...)
+	defer cancel()
+
+	monitor := rpc.NewBlkMonitor(emitter, client, rpc.WithPeriod(5*time.Millisecond))
+	monitor.Start()
+	defer monitor.Stop()
+
+	waitFor(t, "log events", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) >= len(want)
+	})
+	waitFor(t, "scan", func() bool { return monitor.Health().Next == 3 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	for typ, name := range want {
+		if _, ok := received[typ]; !ok {
+			t.Fatalf("%s log event not received", name)
+		}
+	}
+	if len(received) != len(want) {
+		t.Fatalf("unexpected log events: %d, want %d", len(received), len(want))
+	}
+}
+
+func TestBlkMonitor_Payload(t *testing.T) {
+	var (
+		to    = common.HexToAddress(testAddress)
+		inner = common.HexToAddress("0x599d7abdb0a289f85aaca706b55d1b96cc07f348")
+		topic = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	)
+	node, client, key := newTestNode(t, rpctest.WithExecutor(func(from common.Address, tx *ethTypes.Transaction) *rpctest.Execution {
+		return &rpctest.Execution{
+			Status:  ethTypes.ReceiptStatusSuccessful,
+			GasUsed: 30000,
+			Logs: []*ethTypes.Log{
+				{Address: inner, Topics: []common.Hash{topic}, Data: []byte{1}},
+				{Address: inner, Data: []byte{2}},
+			},
+		}
+	}))
+	defer node.Close()
+
+	sent := transfer(t, client, key, to, big.NewInt(7))
+
+	var (
+		mu       sync.Mutex
+		received = make(map[sdk.Type]*sdk.Result)
+	)
+	emitter := sdk.NewEventEmitter(16)
+	txType := sdk.GenHashType(sent.TxHash)
+	logTypes := []sdk.Type{sdk.GenLogType(inner, topic), sdk.GenAnonymousLogType(inner)}
+	cancel := emitter.On(func(e *sdk.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[e.GetType()] = e.GetValue().(*sdk.Result)
+	}, append(logTypes, txType)...)
+	defer cancel()
+
+	monitor := rpc.NewBlkMonitor(emitter, client, rpc.WithPeriod(5*time.Millisecond))
+	monitor.Start()
+	defer monitor.Stop()
+
+	waitFor(t, "events", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 3
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, typ := range append([]sdk.Type{txType}, logTypes...) {
+		res := received[typ]
+		if res.BlockIndex != 1 || res.RoundReceived != 1 {
+			t.Fatalf("unexpected block: %d, round %d", res.BlockIndex, res.RoundReceived)
+		}
+		if res.TxHash != sent.TxHash {
+			t.Fatalf("unexpected tx hash: %s", res.TxHash.Hex())
+		}
+		if res.From != key.GetAddress() || res.To == nil || *res.To != to {
+			t.Fatalf("unexpected from/to: %s, %v", res.From.Hex(), res.To)
+		}
+		if res.Value == nil || res.Value.Cmp(big.NewInt(7)) != 0 {
+			t.Fatalf("unexpected value: %v", res.Value)
+		}
+		if res.GasUsed != 30000 {
+			t.Fatalf("unexpected gas used: %d", res.GasUsed)
+		}
+	}
+
+	if res := received[txType]; res.IsLog || res.LogAddress != (common.Address{}) {
+		t.Fatalf("unexpected tx event: %+v", res)
+	}
+	// subscribers must not share the values of each other
+	if tx, lg := received[txType], received[logTypes[0]]; tx.Value == lg.Value || tx.To == lg.To {
+		t.Fatalf("tx and log events share value or to")
+	}
+	for i, typ := range logTypes {
+		res := received[typ]
+		if !res.IsLog || res.LogIndex != uint(i) || res.LogAddress != inner {
+			t.Fatalf("unexpected log event %d: index %d, address %s", i, res.LogIndex, res.LogAddress.Hex())
+		}
+	}
+}
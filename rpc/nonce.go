@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bolaxy/common"
+	"github.com/pkg/errors"
+)
+
+var nonceErrors = []string{
+	"nonce too low",
+	"nonce too high",
+	"invalid nonce",
+	"nonce gap",
+}
+
+// IsNonceError check whether err is reported by node because of a wrong nonce
+func IsNonceError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, s := range nonceErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// NonceManager local nonce cache, hands out nonces of each account atomically across goroutines
+// the first nonce of an account is fetched from node by FetchAccount,
+// the following nonces are increased locally.
+// a nonce handed out by Next is in flight until it is given back by Release or Done,
+// a resync never goes below the highest nonce in flight, which node does not count yet.
+type NonceManager struct {
+	client API
+
+	mu       sync.Mutex
+	accounts map[common.Address]*accountNonce
+}
+
+type accountNonce struct {
+	mu       sync.Mutex
+	synced   bool
+	base     uint64
+	next     uint64
+	released []uint64
+	inflight map[uint64]struct{}
+}
+
+// NewNonceManager create nonce manager
+func NewNonceManager(client API) *NonceManager {
+	return &NonceManager{
+		client:   client,
+		accounts: make(map[common.Address]*accountNonce),
+	}
+}
+
+// Next hand out the next nonce of address, released nonces are reused first
+func (m *NonceManager) Next(address common.Address) (uint64, error) {
+	return m.NextContext(context.Background(), address)
+}
+
+// NextContext same as Next with context
+func (m *NonceManager) NextContext(ctx context.Context, address common.Address) (uint64, error) {
+	acc := m.account(address)
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	if !acc.synced {
+		if err := m.sync(ctx, address, acc); err != nil {
+			return 0, err
+		}
+	}
+
+	var nonce uint64
+	if len(acc.released) > 0 {
+		nonce = acc.released[0]
+		acc.released = acc.released[1:]
+	} else {
+		nonce = acc.next
+		acc.next++
+	}
+	acc.inflight[nonce] = struct{}{}
+	return nonce, nil
+}
+
+// Done mark a nonce handed out by Next as used, i.e. its tx has been submitted,
+// or the submission failed and node will be asked by Resync
+func (m *NonceManager) Done(address common.Address, nonce uint64) {
+	acc := m.account(address)
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	delete(acc.inflight, nonce)
+}
+
+// Release give back a nonce handed out by Next, which is not used
+// because the send failed before reaching the node.
+// a nonce below the base of the last resync is stale and dropped.
+func (m *NonceManager) Release(address common.Address, nonce uint64) {
+	acc := m.account(address)
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	delete(acc.inflight, nonce)
+	if !acc.synced || nonce < acc.base || nonce >= acc.next {
+		return
+	}
+
+	if nonce+1 == acc.next {
+		acc.next--
+		// the tail released nonces can be merged back
+		for len(acc.released) > 0 && acc.released[len(acc.released)-1]+1 == acc.next {
+			acc.next--
+			acc.released = acc.released[:len(acc.released)-1]
+		}
+		return
+	}
+
+	i := sort.Search(len(acc.released), func(i int) bool { return acc.released[i] >= nonce })
+	if i < len(acc.released) && acc.released[i] == nonce {
+		return
+	}
+	acc.released = append(acc.released, 0)
+	copy(acc.released[i+1:], acc.released[i:])
+	acc.released[i] = nonce
+}
+
+// Resync drop the local state of address and fetch nonce from node again,
+// the next nonce is the larger of the node nonce and the highest nonce in flight plus one.
+// it should be called when node reports nonce too low or a nonce gap
+func (m *NonceManager) Resync(address common.Address) error {
+	return m.ResyncContext(context.Background(), address)
+}
+
+// ResyncContext same as Resync with context
+func (m *NonceManager) ResyncContext(ctx context.Context, address common.Address) error {
+	acc := m.account(address)
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	return m.sync(ctx, address, acc)
+}
+
+// Reset drop the local state of address, the next call of Next will fetch nonce from node
+func (m *NonceManager) Reset(address common.Address) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.accounts, address)
+}
+
+func (m *NonceManager) account(address common.Address) *accountNonce {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	acc, ok := m.accounts[address]
+	if !ok {
+		acc = &accountNonce{inflight: make(map[uint64]struct{})}
+		m.accounts[address] = acc
+	}
+	return acc
+}
+
+func (m *NonceManager) sync(ctx context.Context, address common.Address, acc *accountNonce) error {
+	account, err := m.client.FetchAccountContext(ctx, address.String())
+	if err != nil {
+		acc.synced = false
+		return errors.Wrap(err, "nonceManager[sync]")
+	}
+
+	next := account.Nonce
+	for nonce := range acc.inflight {
+		if nonce >= next {
+			next = nonce + 1
+		}
+	}
+
+	acc.synced = true
+	acc.base = next
+	acc.next = next
+	acc.released = nil
+	return nil
+}
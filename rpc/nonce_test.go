@@ -0,0 +1,208 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bolaxy/common"
+
+	"github.com/bolaxytools/tool-sdk/rpc"
+)
+
+func TestNonceManager(t *testing.T) {
+	var fetched, nonce int32 = 0, 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		fmt.Fprintf(w, `{"Data":{"address":"%s","balance":0,"nonce":%d,"bytecode":""},"Err":""}`,
+			r.URL.Path[len("/account/"):], atomic.LoadInt32(&nonce))
+	}))
+	defer server.Close()
+
+	nm := rpc.NewNonceManager(rpc.Dial(server.URL))
+	addr := common.HexToAddress(testAddress)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		seen  = make(map[uint64]bool)
+		count = 50
+	)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := nm.Next(addr)
+			if err != nil {
+				t.Errorf("Next: %v", err)
+				return
+			}
+			mu.Lock()
+			seen[n] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for n := uint64(5); n < uint64(5+count); n++ {
+		if !seen[n] {
+			t.Fatalf("nonce %d not handed out", n)
+		}
+	}
+	if fetched := atomic.LoadInt32(&fetched); fetched != 1 {
+		t.Fatalf("account fetched %d times, want 1", fetched)
+	}
+
+	nm.Release(addr, 20)
+	if n, _ := nm.Next(addr); n != 20 {
+		t.Fatalf("released nonce not reused: have %d, want 20", n)
+	}
+
+	nm.Release(addr, uint64(5+count-1))
+	if n, _ := nm.Next(addr); n != uint64(5+count-1) {
+		t.Fatalf("released tail nonce not reused: have %d", n)
+	}
+
+	atomic.StoreInt32(&nonce, 100)
+	if err := nm.Resync(addr); err != nil {
+		t.Fatalf("Resync: %v", err)
+	}
+	if n, _ := nm.Next(addr); n != 100 {
+		t.Fatalf("nonce after resync: have %d, want 100", n)
+	}
+
+	if !rpc.IsNonceError(fmt.Errorf("response err -> nonce too low")) {
+		t.Fatalf("IsNonceError: expected true")
+	}
+}
+
+func TestNonceManager_Resync(t *testing.T) {
+	var nonce int32 = 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Data":{"address":"%s","balance":0,"nonce":%d,"bytecode":""},"Err":""}`,
+			r.URL.Path[len("/account/"):], atomic.LoadInt32(&nonce))
+	}))
+	defer server.Close()
+
+	nm := rpc.NewNonceManager(rpc.Dial(server.URL))
+	addr := common.HexToAddress(testAddress)
+
+	// node does not count the nonces in flight, they are not handed out again by a resync
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		seen  = make(map[uint64]bool)
+		count = 50
+	)
+	for i := 0; i < count; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			n, err := nm.Next(addr)
+			if err != nil {
+				t.Errorf("Next: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[n] {
+				t.Errorf("nonce %d handed out twice", n)
+			}
+			seen[n] = true
+		}()
+		go func() {
+			defer wg.Done()
+			if err := nm.Resync(addr); err != nil {
+				t.Errorf("Resync: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for n := range seen {
+		nm.Done(addr, n)
+	}
+	if err := nm.Resync(addr); err != nil {
+		t.Fatalf("Resync: %v", err)
+	}
+	if n, _ := nm.Next(addr); n != 5 {
+		t.Fatalf("nonce after all done: have %d, want 5", n)
+	}
+
+	// a nonce released after a resync above it is stale
+	if n, _ := nm.Next(addr); n != 6 {
+		t.Fatalf("Next: have %d, want 6", n)
+	}
+	atomic.StoreInt32(&nonce, 10)
+	if err := nm.Resync(addr); err != nil {
+		t.Fatalf("Resync: %v", err)
+	}
+	nm.Release(addr, 6)
+	if n, _ := nm.Next(addr); n != 10 {
+		t.Fatalf("stale nonce reused: have %d, want 10", n)
+	}
+}
+
+func TestSendTransaction_NonceManager(t *testing.T) {
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	nm := rpc.NewNonceManager(client)
+	addr := key.GetAddress()
+	to := common.HexToAddress(testAddress)
+	// nonce 0 is taken by a tx in flight, so node is behind the nonce manager
+	if n, err := nm.Next(addr); err != nil || n != 0 {
+		t.Fatalf("Next: %d, %v", n, err)
+	}
+
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	var attempts int32
+	rejectRetry := &rpc.Interceptor{BeforeRequest: func(req *http.Request) error {
+		if atomic.AddInt32(&attempts, 1) > 1 {
+			return &url.Error{Op: req.Method, URL: req.URL.String(), Err: errors.New("rejected")}
+		}
+		return nil
+	}}
+	retryTransact := rpc.WithRetry(&rpc.RetryPolicy{MaxAttempts: 2, RetryTransact: true})
+
+	tests := []struct {
+		name   string
+		client *rpc.Client
+		want   uint64
+	}{
+		{"refused", rpc.Dial(refused.URL, rpc.WithRetry(nil)), 1},
+		{"rejected", rpc.Dial(node.URL(), rpc.WithInterceptor(&rpc.Interceptor{BeforeRequest: func(*http.Request) error {
+			return errors.New("rejected")
+		}})), 1},
+		// node may have received the tx, so the nonce is synced from node again,
+		// but nonce 0 is still in flight and not handed out twice
+		{"bad gateway", rpc.Dial(failing.URL, rpc.WithRetry(nil)), 1},
+		{"retry not sent", rpc.Dial(failing.URL, retryTransact, rpc.WithInterceptor(rejectRetry)), 1},
+	}
+	for _, test := range tests {
+		_, err := test.client.SendTransaction(context.Background(), key, &rpc.SendTxArgs{To: &to, Value: big.NewInt(1)}, rpc.WithNonceManager(nm))
+		if err == nil {
+			t.Fatalf("%s: SendTransaction should fail", test.name)
+		}
+
+		n, err := nm.Next(addr)
+		if err != nil || n != test.want {
+			t.Fatalf("%s: next nonce: have %d, %v, want %d", test.name, n, err, test.want)
+		}
+		// restore the state of the nonce manager
+		nm.Release(addr, n)
+	}
+}
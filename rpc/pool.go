@@ -0,0 +1,419 @@
+package rpc
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/core/types"
+	"github.com/pkg/errors"
+
+	"github.com/bolaxytools/tool-sdk"
+)
+
+var (
+	defaultHealthCheckPeriod = 10 * time.Second
+
+	ErrNoEndpoint = errors.New("no endpoint")
+)
+
+// Strategy how Pool routes read requests
+type Strategy int
+
+const (
+	// RoundRobin route reads to healthy nodes in turn
+	RoundRobin Strategy = iota
+	// MostSynced route reads to the healthy node with the highest last_block_index
+	MostSynced
+)
+
+// PoolOpt options of Pool
+type PoolOpt func(pool *Pool)
+
+// WithStrategy set the routing strategy of reads, default is RoundRobin
+func WithStrategy(strategy Strategy) PoolOpt {
+	return func(pool *Pool) {
+		pool.strategy = strategy
+	}
+}
+
+// WithHealthCheckPeriod set the interval of health checks by FetchChainInfo, default is 10s
+func WithHealthCheckPeriod(period time.Duration) PoolOpt {
+	return func(pool *Pool) {
+		pool.period = period
+	}
+}
+
+// WithDialOpts set the options used to dial every endpoint
+func WithDialOpts(opts ...DialOpt) PoolOpt {
+	return func(pool *Pool) {
+		pool.dialOpts = append(pool.dialOpts, opts...)
+	}
+}
+
+// NodeStatus the health status of an endpoint in Pool
+type NodeStatus struct {
+	Host    string
+	Healthy bool
+	Height  uint64
+	Err     error
+}
+
+// Pool multi-node client with failover and load balancing
+// it has the same method set as Client. reads are routed by the strategy,
+// and fail over to the next node on network errors, 5xx and not found errors;
+// Transact fails over only if the tx was provably not sent, or the clients retry Transact.
+type Pool struct {
+	nodes    []*poolNode
+	strategy Strategy
+	period   time.Duration
+	dialOpts []DialOpt
+	counter  uint64
+	quit     chan struct{}
+	once     sync.Once
+}
+
+type poolNode struct {
+	client *Client
+
+	mu      sync.RWMutex
+	healthy bool
+	height  uint64
+	err     error
+}
+
+// DialPool create pool of endpoints, the health of endpoints is checked once before return
+func DialPool(hosts []string, opts ...PoolOpt) (*Pool, error) {
+	if len(hosts) == 0 {
+		return nil, ErrNoEndpoint
+	}
+
+	pool := &Pool{period: defaultHealthCheckPeriod, quit: make(chan struct{})}
+	for _, opt := range opts {
+		opt(pool)
+	}
+
+	for _, host := range hosts {
+		client := Dial(host, pool.dialOpts...)
+		if err := client.Err(); err != nil {
+			return nil, err
+		}
+		pool.nodes = append(pool.nodes, &poolNode{client: client})
+	}
+
+	pool.checkHealth()
+	go pool.run()
+	return pool, nil
+}
+
+// Close stop health checks
+func (p *Pool) Close() {
+	p.once.Do(func() {
+		close(p.quit)
+	})
+}
+
+// Status the health status of all endpoints
+func (p *Pool) Status() []NodeStatus {
+	status := make([]NodeStatus, 0, len(p.nodes))
+	for _, node := range p.nodes {
+		node.mu.RLock()
+		status = append(status, NodeStatus{
+			Host:    node.client.Host(),
+			Healthy: node.healthy,
+			Height:  node.height,
+			Err:     node.err,
+		})
+		node.mu.RUnlock()
+	}
+	return status
+}
+
+// ChainID the chain id used by the endpoints
+func (p *Pool) ChainID() *big.Int {
+	return p.nodes[0].client.ChainID()
+}
+
+func (p *Pool) run() {
+	ticker := time.NewTicker(p.period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.checkHealth()
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *Pool) checkHealth() {
+	var wg sync.WaitGroup
+	for _, node := range p.nodes {
+		wg.Add(1)
+		go func(node *poolNode) {
+			defer wg.Done()
+			info, err := node.client.FetchChainInfo()
+			if err != nil {
+				node.setStatus(false, 0, err)
+				return
+			}
+
+			last, err := info.LastBlockIndex()
+			if err != nil {
+				node.setStatus(false, 0, errors.Wrap(err, "parse last_block_index"))
+				return
+			}
+			// a fresh node has no block yet
+			var height uint64
+			if last > 0 {
+				height = uint64(last)
+			}
+			node.setStatus(true, height, nil)
+		}(node)
+	}
+	wg.Wait()
+}
+
+func (n *poolNode) setStatus(healthy bool, height uint64, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.healthy = healthy
+	if healthy {
+		n.height = height
+	}
+	n.err = err
+}
+
+func (n *poolNode) status() (bool, uint64) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.healthy, n.height
+}
+
+// candidates the nodes ordered by the strategy, healthy nodes first
+func (p *Pool) candidates() []*poolNode {
+	healthy := make([]*poolNode, 0, len(p.nodes))
+	unhealthy := make([]*poolNode, 0, len(p.nodes))
+	heights := make(map[*poolNode]uint64, len(p.nodes))
+
+	start := int(atomic.AddUint64(&p.counter, 1) % uint64(len(p.nodes)))
+	for i := range p.nodes {
+		node := p.nodes[(start+i)%len(p.nodes)]
+		ok, height := node.status()
+		if ok {
+			healthy = append(healthy, node)
+			heights[node] = height
+		} else {
+			unhealthy = append(unhealthy, node)
+		}
+	}
+
+	if p.strategy == MostSynced {
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return heights[healthy[i]] > heights[healthy[j]]
+		})
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// call fn on the candidates until it succeeds or the error is not failover-able.
+// a read that is not found on a node, e.g. a block above its height, is tried on the rest of nodes,
+// the not found error is returned only if every node reports it
+func (p *Pool) call(ctx context.Context, read bool, fn func(c *Client) error) error {
+	var (
+		err      error
+		notFound error
+		failed   bool
+		sent     bool
+	)
+	for _, node := range p.candidates() {
+		if err = fn(node.client); err == nil {
+			return nil
+		}
+		err = asSent(err, sent)
+		sent = !isUnsent(err)
+
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if read && IsNotFound(err) {
+			// the node may be behind, it is still healthy
+			if notFound == nil {
+				notFound = err
+			}
+			continue
+		}
+		failed = true
+
+		if !IsRetryable(err) {
+			return err
+		}
+		node.setStatus(false, 0, err)
+
+		// the raw tx may have been delivered, resubmitting it to another node is safe only
+		// if the client treats a "known transaction" error as success
+		if !read && !isUnsent(err) && !node.client.retryTransact() {
+			return err
+		}
+	}
+	if notFound != nil && !failed {
+		return notFound
+	}
+	return err
+}
+
+// Transact bolaxy tansfer api, see Client.Transact
+func (p *Pool) Transact(data string) (*RawTxRes, error) {
+	return p.TransactContext(context.Background(), data)
+}
+
+// TransactContext same as Transact with context
+func (p *Pool) TransactContext(ctx context.Context, data string) (res *RawTxRes, err error) {
+	err = p.call(ctx, false, func(c *Client) (err error) {
+		res, err = c.TransactContext(ctx, data)
+		return err
+	})
+	return res, err
+}
+
+// IsContract bolaxy check is contract api, see Client.IsContract
+func (p *Pool) IsContract(address string) (bool, error) {
+	return p.IsContractContext(context.Background(), address)
+}
+
+// IsContractContext same as IsContract with context
+func (p *Pool) IsContractContext(ctx context.Context, address string) (ok bool, err error) {
+	err = p.call(ctx, true, func(c *Client) (err error) {
+		ok, err = c.IsContractContext(ctx, address)
+		return err
+	})
+	return ok, err
+}
+
+// FetchNonce bolaxy fetch nonce api, see Client.FetchNonce
+func (p *Pool) FetchNonce(address string) (uint64, error) {
+	return p.FetchNonceContext(context.Background(), address)
+}
+
+// FetchNonceContext same as FetchNonce with context
+func (p *Pool) FetchNonceContext(ctx context.Context, address string) (nonce uint64, err error) {
+	err = p.call(ctx, true, func(c *Client) (err error) {
+		nonce, err = c.FetchNonceContext(ctx, address)
+		return err
+	})
+	return nonce, err
+}
+
+// FetchBalance bolaxy fetch balance value api, see Client.FetchBalance
+func (p *Pool) FetchBalance(address string) (*big.Int, error) {
+	return p.FetchBalanceContext(context.Background(), address)
+}
+
+// FetchBalanceContext same as FetchBalance with context
+func (p *Pool) FetchBalanceContext(ctx context.Context, address string) (balance *big.Int, err error) {
+	err = p.call(ctx, true, func(c *Client) (err error) {
+		balance, err = c.FetchBalanceContext(ctx, address)
+		return err
+	})
+	return balance, err
+}
+
+// FetchAccount bolaxy fetch account info api, see Client.FetchAccount
+func (p *Pool) FetchAccount(address string) (*JsonAccount, error) {
+	return p.FetchAccountContext(context.Background(), address)
+}
+
+// FetchAccountContext same as FetchAccount with context
+func (p *Pool) FetchAccountContext(ctx context.Context, address string) (acc *JsonAccount, err error) {
+	err = p.call(ctx, true, func(c *Client) (err error) {
+		acc, err = c.FetchAccountContext(ctx, address)
+		return err
+	})
+	return acc, err
+}
+
+// FetchBlock bolaxy fetch block data api, see Client.FetchBlock
+func (p *Pool) FetchBlock(index int) (*types.Block, error) {
+	return p.FetchBlockContext(context.Background(), index)
+}
+
+// FetchBlockContext same as FetchBlock with context
+func (p *Pool) FetchBlockContext(ctx context.Context, index int) (blk *types.Block, err error) {
+	err = p.call(ctx, true, func(c *Client) (err error) {
+		blk, err = c.FetchBlockContext(ctx, index)
+		return err
+	})
+	return blk, err
+}
+
+// FetchReceipt bolaxy fetch receipt api, see Client.FetchReceipt
+func (p *Pool) FetchReceipt(txhash string) (*JsonReceipt, error) {
+	return p.FetchReceiptContext(context.Background(), txhash)
+}
+
+// FetchReceiptContext same as FetchReceipt with context
+func (p *Pool) FetchReceiptContext(ctx context.Context, txhash string) (receipt *JsonReceipt, err error) {
+	err = p.call(ctx, true, func(c *Client) (err error) {
+		receipt, err = c.FetchReceiptContext(ctx, txhash)
+		return err
+	})
+	return receipt, err
+}
+
+// FetchChainInfo bolaxy fetch chain info api, see Client.FetchChainInfo
+func (p *Pool) FetchChainInfo() (*ChainMeta, error) {
+	return p.FetchChainInfoContext(context.Background())
+}
+
+// FetchChainInfoContext same as FetchChainInfo with context
+func (p *Pool) FetchChainInfoContext(ctx context.Context) (meta *ChainMeta, err error) {
+	err = p.call(ctx, true, func(c *Client) (err error) {
+		meta, err = c.FetchChainInfoContext(ctx)
+		return err
+	})
+	return meta, err
+}
+
+// CallContract bolaxy call contract api, see Client.CallContract
+func (p *Pool) CallContract(msg *SendTxArgs) ([]byte, error) {
+	return p.CallContractContext(context.Background(), msg)
+}
+
+// CallContractContext same as CallContract with context
+func (p *Pool) CallContractContext(ctx context.Context, msg *SendTxArgs) (result []byte, err error) {
+	err = p.call(ctx, true, func(c *Client) (err error) {
+		result, err = c.CallContractContext(ctx, msg)
+		return err
+	})
+	return result, err
+}
+
+// FetchBlocks see Client.FetchBlocks, each block is routed by the strategy
+func (p *Pool) FetchBlocks(ctx context.Context, from, to int, opts ...BlocksOpt) ([]*types.Block, error) {
+	return fetchBlocks(ctx, p, from, to, opts...)
+}
+
+// StreamBlocks see Client.StreamBlocks, each block is routed by the strategy
+func (p *Pool) StreamBlocks(ctx context.Context, from, to int, opts ...BlocksOpt) (<-chan *types.Block, <-chan error) {
+	return streamBlocks(ctx, p, from, to, opts...)
+}
+
+// SendTransaction see Client.SendTransaction
+func (p *Pool) SendTransaction(ctx context.Context, signer sdk.Signer, args *SendTxArgs, opts ...SendOpt) (*SendTxResult, error) {
+	return sendTransaction(ctx, p, signer, args, opts...)
+}
+
+// WaitReceipt see Client.WaitReceipt
+func (p *Pool) WaitReceipt(ctx context.Context, txhash common.Hash, pollInterval time.Duration) (*JsonReceipt, error) {
+	return waitReceipt(ctx, p, txhash, pollInterval)
+}
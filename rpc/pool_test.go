@@ -0,0 +1,257 @@
+package rpc_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bolaxytools/tool-sdk/rpc"
+	"github.com/bolaxytools/tool-sdk/rpc/rpctest"
+)
+
+// poolServer fake node serving chain info, account, receipt and rawtx
+type poolServer struct {
+	*httptest.Server
+	height int
+	// down respond 503 to all requests
+	down int32
+	// failTx respond 503 to rawtx requests
+	failTx int32
+	// hits the number of requests except chain info
+	hits int32
+}
+
+func newPoolServer(height int) *poolServer {
+	s := &poolServer{height: height}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&s.down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/info" {
+			fmt.Fprintf(w, `{"Data":{"last_block_index":"%d","state":"Babbling"},"Err":""}`, s.height)
+			return
+		}
+
+		atomic.AddInt32(&s.hits, 1)
+		switch {
+		case r.URL.Path == "/rawtx" && atomic.LoadInt32(&s.failTx) == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/rawtx":
+			fmt.Fprint(w, `{"Data":{"txHash":"0x01","contractAddr":""},"Err":""}`)
+		case strings.HasPrefix(r.URL.Path, "/tx/"):
+			fmt.Fprint(w, `{"Data":null,"Err":"tx not found"}`)
+		default:
+			fmt.Fprintf(w, `{"Data":{"address":"%s","balance":0,"nonce":%d,"bytecode":""},"Err":""}`, testAddress, s.height)
+		}
+	}))
+	return s
+}
+
+func dialPool(t *testing.T, servers []*poolServer, opts ...rpc.PoolOpt) *rpc.Pool {
+	hosts := make([]string, 0, len(servers))
+	for _, s := range servers {
+		hosts = append(hosts, s.URL)
+	}
+
+	pool, err := rpc.DialPool(hosts, opts...)
+	if err != nil {
+		t.Fatalf("DialPool: %v", err)
+	}
+	return pool
+}
+
+func TestPool(t *testing.T) {
+	lagging := newPoolServer(5)
+	defer lagging.Close()
+	synced := newPoolServer(10)
+	defer synced.Close()
+
+	pool := dialPool(t, []*poolServer{lagging, synced},
+		rpc.WithStrategy(rpc.MostSynced), rpc.WithDialOpts(rpc.WithRetry(nil)))
+	defer pool.Close()
+
+	for i := 0; i < 4; i++ {
+		meta, err := pool.FetchChainInfo()
+		if err != nil {
+			t.Fatalf("FetchChainInfo: %v", err)
+		}
+		if meta.BlockHeight != "10" {
+			t.Fatalf("routed to lagging node: height %s", meta.BlockHeight)
+		}
+	}
+
+	atomic.StoreInt32(&synced.down, 1)
+	nonce, err := pool.FetchNonce(testAddress)
+	if err != nil {
+		t.Fatalf("FetchNonce: %v", err)
+	}
+	if nonce != 5 {
+		t.Fatalf("nonce: have %d, want 5 from the lagging node", nonce)
+	}
+
+	status := pool.Status()
+	if !status[0].Healthy || status[1].Healthy {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	if _, err := rpc.DialPool(nil); err != rpc.ErrNoEndpoint {
+		t.Fatalf("DialPool: have %v, want %v", err, rpc.ErrNoEndpoint)
+	}
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	a := newPoolServer(5)
+	defer a.Close()
+	b := newPoolServer(10)
+	defer b.Close()
+
+	pool := dialPool(t, []*poolServer{a, b}, rpc.WithDialOpts(rpc.WithRetry(nil)))
+	defer pool.Close()
+
+	seen := make(map[uint64]int)
+	for i := 0; i < 4; i++ {
+		nonce, err := pool.FetchNonce(testAddress)
+		if err != nil {
+			t.Fatalf("FetchNonce: %v", err)
+		}
+		seen[nonce]++
+	}
+	if seen[5] != 2 || seen[10] != 2 {
+		t.Fatalf("reads are not routed in turn: %v", seen)
+	}
+}
+
+func TestPool_Recovery(t *testing.T) {
+	a := newPoolServer(5)
+	defer a.Close()
+	b := newPoolServer(10)
+	defer b.Close()
+
+	pool := dialPool(t, []*poolServer{a, b},
+		rpc.WithHealthCheckPeriod(10*time.Millisecond), rpc.WithDialOpts(rpc.WithRetry(nil)))
+	defer pool.Close()
+
+	waitHealthy := func(want bool) {
+		deadline := time.Now().Add(2 * time.Second)
+		for pool.Status()[1].Healthy != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("node healthy: have %v, want %v", !want, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	atomic.StoreInt32(&b.down, 1)
+	waitHealthy(false)
+	atomic.StoreInt32(&b.down, 0)
+	waitHealthy(true)
+
+	seen := make(map[uint64]bool)
+	for i := 0; i < 2; i++ {
+		nonce, err := pool.FetchNonce(testAddress)
+		if err != nil {
+			t.Fatalf("FetchNonce: %v", err)
+		}
+		seen[nonce] = true
+	}
+	if !seen[10] {
+		t.Fatalf("recovered node is not routed to: %v", seen)
+	}
+}
+
+func TestPool_NotFound(t *testing.T) {
+	a := newPoolServer(5)
+	defer a.Close()
+	b := newPoolServer(10)
+	defer b.Close()
+
+	pool := dialPool(t, []*poolServer{a, b}, rpc.WithDialOpts(rpc.WithRetry(nil)))
+	defer pool.Close()
+
+	if _, err := pool.FetchReceipt("0x01"); !rpc.IsNotFound(err) {
+		t.Fatalf("FetchReceipt: have %v, want not found", err)
+	}
+	// not found is returned after every node reports it, and does not mark the nodes unhealthy
+	if hits := atomic.LoadInt32(&a.hits) + atomic.LoadInt32(&b.hits); hits != 2 {
+		t.Fatalf("not found: %d requests, want 2", hits)
+	}
+	for _, status := range pool.Status() {
+		if !status.Healthy {
+			t.Fatalf("unexpected status: %+v", status)
+		}
+	}
+}
+
+func TestPool_LaggingNode(t *testing.T) {
+	synced := rpctest.NewNode()
+	defer synced.Close()
+	lagging := rpctest.NewNode()
+	defer lagging.Close()
+	for synced.BlockHeight() < 5 {
+		synced.AddBlock()
+	}
+	for lagging.BlockHeight() < 1 {
+		lagging.AddBlock()
+	}
+
+	pool, err := rpc.DialPool([]string{synced.URL(), lagging.URL()}, rpc.WithDialOpts(rpc.WithRetry(nil)))
+	if err != nil {
+		t.Fatalf("DialPool: %v", err)
+	}
+	defer pool.Close()
+
+	// round robin may route the blocks above the lagging node to it, they are read from the synced node
+	for i := 0; i < 6; i++ {
+		if _, err := pool.FetchBlocks(context.Background(), 0, -1); err != nil {
+			t.Fatalf("FetchBlocks: %v", err)
+		}
+		blks, err := pool.FetchBlocks(context.Background(), 0, 5)
+		if err != nil {
+			t.Fatalf("FetchBlocks: %v", err)
+		}
+		if len(blks) != 6 {
+			t.Fatalf("FetchBlocks: have %d blocks, want 6", len(blks))
+		}
+	}
+
+	if _, err := pool.FetchBlock(6); !rpc.IsNotFound(err) {
+		t.Fatalf("FetchBlock: have %v, want not found", err)
+	}
+}
+
+func TestPool_Transact(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		retry *rpc.RetryPolicy
+		hits  int32
+	}{
+		{"NoRetryTransact", nil, 1},
+		{"RetryTransact", &rpc.RetryPolicy{MaxAttempts: 1, RetryTransact: true}, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newPoolServer(5)
+			defer a.Close()
+			b := newPoolServer(10)
+			defer b.Close()
+			atomic.StoreInt32(&a.failTx, 1)
+			atomic.StoreInt32(&b.failTx, 1)
+
+			pool := dialPool(t, []*poolServer{a, b}, rpc.WithDialOpts(rpc.WithRetry(tc.retry)))
+			defer pool.Close()
+
+			if _, err := pool.Transact("0x01"); err == nil {
+				t.Fatalf("Transact: expected error")
+			}
+			// a 503 may be returned after the tx was delivered, it is resubmitted only with RetryTransact
+			if hits := atomic.LoadInt32(&a.hits) + atomic.LoadInt32(&b.hits); hits != tc.hits {
+				t.Fatalf("Transact: %d requests, want %d", hits, tc.hits)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package rpc
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var knownTxErrors = []string{
+	"already known",
+	"known transaction",
+	"already exists",
+}
+
+// RetryPolicy retry settings of Client requests
+// read requests are retried automatically, Transact is retried only if RetryTransact is set.
+type RetryPolicy struct {
+	// MaxAttempts the max number of attempts including the first one, 1 means no retry
+	MaxAttempts int
+	// InitialBackoff the wait time before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff the upper bound of wait time between attempts
+	MaxBackoff time.Duration
+	// Multiplier the factor the backoff grows after each retry
+	Multiplier float64
+	// Jitter randomize the backoff by ±Jitter, in range [0, 1]
+	Jitter float64
+	// Retryable decide which errors to retry, default is IsRetryable
+	Retryable func(err error) bool
+	// RetryTransact also retry Transact. it is safe because the resubmitted raw tx has the same hash,
+	// if node reports the tx is already known, Transact returns its hash.
+	RetryTransact bool
+}
+
+// DefaultRetryPolicy 3 attempts with exponential backoff from 200ms to 2s
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		Retryable:      IsRetryable,
+	}
+}
+
+// WithRetry set the retry policy of Client, default is DefaultRetryPolicy, nil disables retry
+func WithRetry(policy *RetryPolicy) DialOpt {
+	return func(client *Client) {
+		client.retry = policy
+	}
+}
+
+// IsRetryable the default retryable errors: network failures, 429 and 5xx http status except 501
+// node errors, decode errors and not found errors are not retried
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests ||
+			(statusErr.StatusCode >= 500 && statusErr.StatusCode != http.StatusNotImplemented)
+	}
+
+	var nodeErr *NodeError
+	if errors.As(err, &nodeErr) {
+		return statusRetryable(nodeErr.StatusCode)
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func statusRetryable(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// isKnownTxError check whether node reports the submitted tx is already in pool or chain
+func isKnownTxError(err error) bool {
+	var nodeErr *NodeError
+	if !errors.As(err, &nodeErr) {
+		return false
+	}
+
+	msg := strings.ToLower(nodeErr.Message)
+	for _, s := range knownTxErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// backoff the wait time before the retry-th retry, begin at 0
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		d = d * (1 - p.Jitter + 2*p.Jitter*rand.Float64())
+	}
+	return time.Duration(d)
+}
+
+// withRetry call fn until it succeeds, the error is not retryable, attempts are used up or ctx is done
+func (p *RetryPolicy) withRetry(ctx context.Context, fn func() error) error {
+	attempts := p.attempts()
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || i+1 >= attempts || ctx.Err() != nil || !p.retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(p.backoff(i))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package rpc_test
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bolaxy/common"
+
+	"github.com/bolaxytools/tool-sdk"
+	"github.com/bolaxytools/tool-sdk/rpc"
+)
+
+func TestClient_Retry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/rawtx":
+			if n%2 == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"Data":null,"Err":"already known"}`))
+		default:
+			if n < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprintf(w, `{"Data":{"address":"%s","balance":0,"nonce":3,"bytecode":""},"Err":""}`, testAddress)
+		}
+	}))
+	defer server.Close()
+
+	policy := rpc.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	c := rpc.Dial(server.URL, rpc.WithRetry(policy))
+
+	if nonce, err := c.FetchNonce(testAddress); err != nil || nonce != 3 {
+		t.Fatalf("FetchNonce: %d, %v", nonce, err)
+	}
+	if calls != 3 {
+		t.Fatalf("calls: have %d, want 3", calls)
+	}
+
+	key, _ := sdk.GenerateKey()
+	tx, raw, err := sdk.NewTxBuilder().To(common.Address{0x01}).Value(big.NewInt(1)).Gas(21000).Nonce(0).Sign(key)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	if _, err := c.Transact(raw); err == nil || calls != 1 {
+		t.Fatalf("Transact: calls %d, err %v", calls, err)
+	}
+
+	policy.RetryTransact = true
+	atomic.StoreInt32(&calls, 0)
+	res, err := c.Transact(raw)
+	if err != nil {
+		t.Fatalf("Transact: %v", err)
+	}
+	if res.TxHash != tx.Hash().String() || calls != 2 {
+		t.Fatalf("Transact: hash %s, calls %d", res.TxHash, calls)
+	}
+}
@@ -2,39 +2,107 @@ package rpc
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"math/big"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bolaxy/common"
 	"github.com/bolaxy/common/hexutil"
 	"github.com/bolaxy/core/types"
 	ethTypes "github.com/bolaxy/eth/types"
+	"github.com/bolaxy/rlp"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
 )
 
+// ErrCustomTransport WithTLSConfig or WithProxy is used with an http client whose transport is not an *http.Transport
+var ErrCustomTransport = errors.New("tls config and proxy require an *http.Transport")
+
 // Client bolaxy client
 type Client struct {
-	host        string
-	decoderPool sync.Pool
+	host         string
+	chainID      *big.Int
+	http         *http.Client
+	timeout      time.Duration
+	headers      http.Header
+	tlsConfig    *tls.Config
+	proxy        func(*http.Request) (*url.URL, error)
+	retry        *RetryPolicy
+	interceptors []*Interceptor
+	decoderPool  sync.Pool
+	err          error
+}
+
+// DialOpt options of Client
+type DialOpt func(client *Client)
+
+// WithChainID set the chain id used to verify transaction signatures,
+// default is common.ChainID
+func WithChainID(chainID *big.Int) DialOpt {
+	return func(client *Client) {
+		client.chainID = chainID
+	}
+}
+
+// WithHTTPClient set the http client used to request node, default is http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) DialOpt {
+	return func(client *Client) {
+		client.http = httpClient
+	}
+}
+
+// WithTimeout set the timeout of each request, default is 30s, 0 means no timeout
+func WithTimeout(timeout time.Duration) DialOpt {
+	return func(client *Client) {
+		client.timeout = timeout
+	}
+}
+
+// WithTLSConfig set the tls configuration for https node,
+// the transport of WithHTTPClient must be nil or an *http.Transport
+func WithTLSConfig(config *tls.Config) DialOpt {
+	return func(client *Client) {
+		client.tlsConfig = config
+	}
+}
+
+// WithProxy set the proxy of requests, e.g. http.ProxyURL(proxyUrl),
+// the transport of WithHTTPClient must be nil or an *http.Transport
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) DialOpt {
+	return func(client *Client) {
+		client.proxy = proxy
+	}
+}
+
+// WithHeader add extra header to every request, e.g. auth token of the gateway
+func WithHeader(key, value string) DialOpt {
+	return func(client *Client) {
+		if client.headers == nil {
+			client.headers = make(http.Header)
+		}
+		client.headers.Add(key, value)
+	}
 }
 
 // Dial http client api
-func Dial(host string) *Client {
+// an invalid combination of options is reported by Err, and every request fails with it
+func Dial(host string, opts ...DialOpt) *Client {
 	if host == "" {
 		host = defaultHost
 	}
-	return &Client{host: host, decoderPool: sync.Pool{New: func() interface{} {
+	client := &Client{host: host, timeout: defaultTimeout, retry: DefaultRetryPolicy(), decoderPool: sync.Pool{New: func() interface{} {
 		return NewDecoder(
 			WithHook(float64ToBigInt),
 			WithHook(float64ToUint64),
@@ -46,6 +114,57 @@ func Dial(host string) *Client {
 			WithHook(hexToUint64OrUint),
 		)
 	}}}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	if client.chainID == nil {
+		client.chainID = common.ChainID
+	}
+
+	if client.http == nil {
+		client.http = http.DefaultClient
+	}
+
+	if client.tlsConfig != nil || client.proxy != nil {
+		transport, ok := client.http.Transport.(*http.Transport)
+		if !ok {
+			if client.http.Transport != nil {
+				client.err = errors.Wrapf(ErrCustomTransport, "dial: transport is %T", client.http.Transport)
+				return client
+			}
+			transport = http.DefaultTransport.(*http.Transport)
+		}
+		transport = transport.Clone()
+		if client.tlsConfig != nil {
+			transport.TLSClientConfig = client.tlsConfig
+		}
+		if client.proxy != nil {
+			transport.Proxy = client.proxy
+		}
+
+		httpClient := *client.http
+		httpClient.Transport = transport
+		client.http = &httpClient
+	}
+
+	return client
+}
+
+// ChainID the chain id used by this client
+func (c *Client) ChainID() *big.Int {
+	return c.chainID
+}
+
+// Err the error of invalid dial options, nil if the client is usable
+func (c *Client) Err() error {
+	return c.err
+}
+
+// Host the node address of this client
+func (c *Client) Host() string {
+	return c.host
 }
 
 // Decoder json response decoder, can be reuse in next time
@@ -87,24 +206,48 @@ func NewDecoder(opts ...DecoderOpt) Decoder {
 // Transact bolaxy tansfer api
 // the parameter data is hex encoded string that been rlp serialized data.
 func (c *Client) Transact(data string) (*RawTxRes, error) {
-	svcUrl := c.host + transferUrl
-	payload, err := post(svcUrl, "text/plain", strings.NewReader(data))
-	if err != nil {
-		return nil, errors.Wrap(err, "transfer[post]")
+	return c.TransactContext(context.Background(), data)
+}
+
+// TransactContext same as Transact with context
+// if the retry policy enables RetryTransact, the submission is retried,
+// and a "known transaction" node error returns the hash of data as success.
+func (c *Client) TransactContext(ctx context.Context, data string) (*RawTxRes, error) {
+	retryTransact := c.retryTransact()
+	payload, err := c.post(ctx, transferUrl, "text/plain", []byte(data), retryTransact)
+	if err == nil {
+		var res RawTxRes
+		if err = c.decode(payload, &res); err == nil {
+			return &res, nil
+		}
+		err = errors.Wrap(err, "transfer[unmarshal]")
+	} else {
+		err = errors.Wrap(err, "transfer[post]")
 	}
 
-	var res RawTxRes
-	if err = c.decode(payload, &res); err != nil {
-		return nil, errors.Wrap(err, "transfer[unmarshal]")
+	if retryTransact && isKnownTxError(err) {
+		var tx ethTypes.Transaction
+		if rlp.DecodeBytes(common.FromHex(data), &tx) == nil {
+			return &RawTxRes{TxHash: tx.Hash().String()}, nil
+		}
 	}
+	return nil, err
+}
 
-	return &res, nil
+// retryTransact whether the retry policy enables RetryTransact
+func (c *Client) retryTransact() bool {
+	return c.retry != nil && c.retry.RetryTransact
 }
 
 // IsContract bolaxy check  is contract api
 // the parameter address is hex formated string, e.g. 0x599d7abdb0a289f85aaca706b55d1b96cc07f348
 func (c *Client) IsContract(address string) (bool, error) {
-	acc, err := c.FetchAccount(address)
+	return c.IsContractContext(context.Background(), address)
+}
+
+// IsContractContext same as IsContract with context
+func (c *Client) IsContractContext(ctx context.Context, address string) (bool, error) {
+	acc, err := c.FetchAccountContext(ctx, address)
 	if err != nil {
 		return false, errors.Wrap(err, "isContract")
 	}
@@ -119,7 +262,12 @@ func (c *Client) IsContract(address string) (bool, error) {
 // FetchNonce bolaxy fetch nonce api
 // the parameter address is hex formated string, e.g. 0x599d7abdb0a289f85aaca706b55d1b96cc07f348
 func (c *Client) FetchNonce(address string) (uint64, error) {
-	acc, err := c.FetchAccount(address)
+	return c.FetchNonceContext(context.Background(), address)
+}
+
+// FetchNonceContext same as FetchNonce with context
+func (c *Client) FetchNonceContext(ctx context.Context, address string) (uint64, error) {
+	acc, err := c.FetchAccountContext(ctx, address)
 	if err != nil {
 		return 0, errors.Wrap(err, "fetchNonce")
 	}
@@ -129,7 +277,12 @@ func (c *Client) FetchNonce(address string) (uint64, error) {
 // FetchBalance bolaxy fetch balance value api
 // the parameter address is hex formated string, e.g. 0x599d7abdb0a289f85aaca706b55d1b96cc07f348
 func (c *Client) FetchBalance(address string) (*big.Int, error) {
-	acc, err := c.FetchAccount(address)
+	return c.FetchBalanceContext(context.Background(), address)
+}
+
+// FetchBalanceContext same as FetchBalance with context
+func (c *Client) FetchBalanceContext(ctx context.Context, address string) (*big.Int, error) {
+	acc, err := c.FetchAccountContext(ctx, address)
 	if err != nil {
 		return nil, errors.Wrap(err, "fetchBalance")
 	}
@@ -140,14 +293,19 @@ func (c *Client) FetchBalance(address string) (*big.Int, error) {
 // FetchAccount bolaxy fetch account info api
 // the parameter address is hex formated string, e.g. 0x599d7abdb0a289f85aaca706b55d1b96cc07f348
 func (c *Client) FetchAccount(address string) (*JsonAccount, error) {
-	payload, err := get(c.host, accountUrl, address)
+	return c.FetchAccountContext(context.Background(), address)
+}
+
+// FetchAccountContext same as FetchAccount with context
+func (c *Client) FetchAccountContext(ctx context.Context, address string) (*JsonAccount, error) {
+	payload, err := c.get(ctx, accountUrl, address)
 	if err != nil {
-		return nil, errors.Wrap(err, "fetchAccount[get]")
+		return nil, errors.Wrap(asNotFound(err, "account", address), "fetchAccount[get]")
 	}
 
 	var acc JsonAccount
 	if err = c.decode(payload, &acc); err != nil {
-		return nil, errors.Wrap(err, "fetchAccount[unmarshal]")
+		return nil, errors.Wrap(asNotFound(err, "account", address), "fetchAccount[unmarshal]")
 	}
 
 	return &acc, nil
@@ -157,14 +315,19 @@ func (c *Client) FetchAccount(address string) (*JsonAccount, error) {
 // the parameter index is the blockheight, begin at 0
 // if no block exist, will return error
 func (c *Client) FetchBlock(index int) (*types.Block, error) {
-	payload, err := get(c.host, blkSvcUrl, strconv.Itoa(index))
+	return c.FetchBlockContext(context.Background(), index)
+}
+
+// FetchBlockContext same as FetchBlock with context
+func (c *Client) FetchBlockContext(ctx context.Context, index int) (*types.Block, error) {
+	payload, err := c.get(ctx, blkSvcUrl, strconv.Itoa(index))
 	if err != nil {
-		return nil, errors.Wrap(err, "fetchBlock[get]")
+		return nil, errors.Wrap(asNotFound(err, "block", strconv.Itoa(index)), "fetchBlock[get]")
 	}
 
 	var blk types.Block
 	if err = c.decode(payload, &blk); err != nil {
-		return nil, errors.Wrap(err, "fetchBlock[unmarshal]")
+		return nil, errors.Wrap(asNotFound(err, "block", strconv.Itoa(index)), "fetchBlock[unmarshal]")
 	}
 
 	return &blk, nil
@@ -173,23 +336,42 @@ func (c *Client) FetchBlock(index int) (*types.Block, error) {
 // FetchReceipt bolaxy fetch receipt api
 // txhash is hex string that is transaction hash
 func (c *Client) FetchReceipt(txhash string) (*JsonReceipt, error) {
-	payload, err := get(c.host, receiptUrl, txhash)
+	return c.FetchReceiptContext(context.Background(), txhash)
+}
+
+// FetchReceiptContext same as FetchReceipt with context
+func (c *Client) FetchReceiptContext(ctx context.Context, txhash string) (*JsonReceipt, error) {
+	payload, err := c.get(ctx, receiptUrl, txhash)
+	if err != nil {
+		return nil, errors.Wrap(asNotFound(err, "receipt", txhash), "fetchReceipt[get]")
+	}
+
+	var resp jsonReceipt
+	if err = c.decode(payload, &resp); err != nil {
+		return nil, errors.Wrap(asNotFound(err, "receipt", txhash), "fetchReceipt[unmarshal]")
+	}
+
+	receipt, err := resp.receipt()
 	if err != nil {
-		return nil, errors.Wrap(err, "fetchReceipt[get]")
+		return nil, errors.Wrap(&DecodeError{Body: truncateBody(payload), Err: err}, "fetchReceipt[unmarshal]")
 	}
 
-	var receipt JsonReceipt
-	if err = c.decode(payload, &receipt); err != nil {
-		return nil, errors.Wrap(err, "fetchReceipt[unmarshal]")
+	if receipt.TransactionHash == (common.Hash{}) {
+		return nil, &NotFoundError{Resource: "receipt", Key: txhash, Err: errors.New("empty receipt")}
 	}
 
-	return &receipt, nil
+	return receipt, nil
 }
 
 // FetchChainInfo bolaxy fetch chain info api
 // this api will get current block height and block chain status
 func (c *Client) FetchChainInfo() (*ChainMeta, error) {
-	payload, err := get(c.host, infoUrl)
+	return c.FetchChainInfoContext(context.Background())
+}
+
+// FetchChainInfoContext same as FetchChainInfo with context
+func (c *Client) FetchChainInfoContext(ctx context.Context) (*ChainMeta, error) {
+	payload, err := c.get(ctx, infoUrl)
 	if err != nil {
 		return nil, errors.Wrap(err, "fetchChainInfo[get]")
 	}
@@ -214,13 +396,17 @@ func (c *Client) FetchChainInfo() (*ChainMeta, error) {
 // 	"nonce": 1
 // }
 func (c *Client) CallContract(msg *SendTxArgs) ([]byte, error) {
+	return c.CallContractContext(context.Background(), msg)
+}
+
+// CallContractContext same as CallContract with context
+func (c *Client) CallContractContext(ctx context.Context, msg *SendTxArgs) ([]byte, error) {
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		return nil, errors.Wrap(err, "callContract[marshal sendtxargs]")
 	}
 
-	callURL := c.host + callSvcUrl
-	payload, err = post(callURL, "application/json", bytes.NewBuffer(payload))
+	payload, err = c.post(ctx, callSvcUrl, "application/json", payload, true)
 	if err != nil {
 		return nil, errors.Wrap(err, "callContract[post]")
 	}
@@ -245,28 +431,111 @@ func (c *Client) decode(data []byte, output interface{}) error {
 	return decoder.Decode(data, output)
 }
 
-func get(getUrl ...string) ([]byte, error) {
-	// fmt.Printf("get url: %s\n", strings.Join(getUrl, ""))
-	resp, err := http.Get(strings.Join(getUrl, ""))
+func (c *Client) get(ctx context.Context, path ...string) ([]byte, error) {
+	return c.request(ctx, http.MethodGet, c.host+strings.Join(path, ""), "", nil, true)
+}
+
+// post idempotent means the request can be retried safely
+func (c *Client) post(ctx context.Context, path, contentType string, body []byte, idempotent bool) ([]byte, error) {
+	return c.request(ctx, http.MethodPost, c.host+path, contentType, body, idempotent)
+}
+
+func (c *Client) request(ctx context.Context, method, reqUrl, contentType string, body []byte, idempotent bool) ([]byte, error) {
+	if c.err != nil {
+		return nil, &unsentError{err: c.err}
+	}
+
+	if !idempotent || c.retry == nil {
+		return c.do(ctx, method, reqUrl, contentType, body)
+	}
+
+	var (
+		payload []byte
+		sent    bool
+	)
+	err := c.retry.withRetry(ctx, func() (err error) {
+		payload, err = c.do(ctx, method, reqUrl, contentType, body)
+		sent = sent || (err != nil && !isUnsent(err))
+		return err
+	})
+	return payload, asSent(err, sent)
+}
+
+func (c *Client) do(ctx context.Context, method, reqUrl, contentType string, body []byte) ([]byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, reader)
 	if err != nil {
 		return nil, err
 	}
 
-	return readResp(resp.Body)
+	for key, values := range c.headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	start := time.Now()
+	payload, err := c.exchange(req, start)
+	if err != nil {
+		c.onError(req, err, time.Since(start))
+		return nil, err
+	}
+	return payload, nil
 }
 
-func post(postUrl, contentType string, body io.Reader) ([]byte, error) {
-	resp, err := http.Post(postUrl, contentType, body)
+// exchange send req through the interceptors and read the response
+func (c *Client) exchange(req *http.Request, start time.Time) ([]byte, error) {
+	if err := c.beforeRequest(req); err != nil {
+		return nil, &unsentError{err: err}
+	}
+
+	resp, err := c.http.Do(req)
 	if err != nil {
+		return nil, asUnsent(err)
+	}
+
+	payload, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.afterResponse(req, resp, payload, time.Since(start)); err != nil {
 		return nil, err
 	}
 
-	return readResp(resp.Body)
+	return checkStatus(resp, payload)
+}
+
+func readBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
 }
 
-func readResp(reader io.ReadCloser) ([]byte, error) {
-	defer reader.Close()
-	return ioutil.ReadAll(reader)
+func checkStatus(resp *http.Response, payload []byte) ([]byte, error) {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var res struct {
+			Err string
+		}
+		if json.Unmarshal(payload, &res) == nil && res.Err != "" {
+			return nil, &NodeError{StatusCode: resp.StatusCode, Message: res.Err}
+		}
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: truncateBody(payload)}
+	}
+
+	return payload, nil
 }
 
 var typeBig = big.NewInt(0)
@@ -308,7 +577,11 @@ func hexToAddress(f reflect.Type, t reflect.Type, data interface{}) (interface{}
 		return data, nil
 	}
 
+	// a null pointer, the hooks composed after this one get a nil from type
 	if data.(string) == "null" {
+		if t == reflect.TypeOf(common.Address{}) {
+			return common.Address{}, nil
+		}
 		return nil, nil
 	}
 
@@ -322,7 +595,7 @@ func hexToAddress(f reflect.Type, t reflect.Type, data interface{}) (interface{}
 }
 
 func base64ToSlice(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
-	if f.Kind() != reflect.String || t != reflect.TypeOf(make([]byte, 0)) {
+	if f == nil || f.Kind() != reflect.String || t != reflect.TypeOf(make([]byte, 0)) {
 		return data, nil
 	}
 
@@ -340,7 +613,7 @@ func base64ToSlice(f reflect.Type, t reflect.Type, data interface{}) (interface{
 }
 
 func base64ToArray(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
-	if f.Kind() != reflect.Slice || t != reflect.TypeOf(make([][]byte, 0)) {
+	if f == nil || f.Kind() != reflect.Slice || t != reflect.TypeOf(make([][]byte, 0)) {
 		return data, nil
 	}
 
@@ -362,7 +635,7 @@ func base64ToArray(f reflect.Type, t reflect.Type, data interface{}) (interface{
 
 func hexToBloom(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
 	var bloom ethTypes.Bloom
-	if f.Kind() != reflect.String || t != reflect.TypeOf(bloom) {
+	if f == nil || f.Kind() != reflect.String || t != reflect.TypeOf(bloom) {
 		return data, nil
 	}
 
@@ -375,8 +648,49 @@ func hexToBloom(f reflect.Type, t reflect.Type, data interface{}) (interface{},
 	return bloom, nil
 }
 
+func stringToNodeState(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String || t != reflect.TypeOf(StateUnknown) {
+		return data, nil
+	}
+
+	return ParseNodeState(data.(string)), nil
+}
+
+// stringToIndex block index or round of chain info, "nil" and "" are -1
+func stringToIndex(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String || t.Kind() != reflect.Int64 {
+		return data, nil
+	}
+
+	return parseIndex(data.(string))
+}
+
+// decimalToUint64 decimal string of chain info counters, "" is 0
+func decimalToUint64(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String || t.Kind() != reflect.Uint64 {
+		return data, nil
+	}
+
+	if data.(string) == "" {
+		return uint64(0), nil
+	}
+	return strconv.ParseUint(data.(string), 10, 64)
+}
+
+// stringToFloat64 decimal string of chain info rates, "" is 0
+func stringToFloat64(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String || t.Kind() != reflect.Float64 {
+		return data, nil
+	}
+
+	if data.(string) == "" {
+		return float64(0), nil
+	}
+	return strconv.ParseFloat(data.(string), 64)
+}
+
 func hexToUint64OrUint(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
-	if f.Kind() != reflect.String || (t.Kind() != reflect.Uint64 && t.Kind() != reflect.Int) {
+	if f == nil || f.Kind() != reflect.String || (t.Kind() != reflect.Uint64 && t.Kind() != reflect.Int) {
 		return data, nil
 	}
 
@@ -410,13 +724,12 @@ func (r *responseDecoder) Decode(result []byte, output interface{}) error {
 		r.result = make(map[string]interface{})
 	}
 
-	// fmt.Printf("decode --> %s\n", result)
 	if err := json.Unmarshal(result, &r.result); err != nil {
-		return errors.Wrap(err, "json unmarshal")
+		return &DecodeError{Body: truncateBody(result), Err: errors.Wrap(err, "json unmarshal")}
 	}
 
-	if r.result["Err"] != "" {
-		return errors.New(fmt.Sprintf("response err -> %s", r.result["Err"].(string)))
+	if msg, _ := r.result["Err"].(string); msg != "" {
+		return &NodeError{StatusCode: http.StatusOK, Message: msg}
 	}
 
 	config := &mapstructure.DecoderConfig{Result: output}
@@ -431,5 +744,8 @@ func (r *responseDecoder) Decode(result []byte, output interface{}) error {
 		return err
 	}
 
-	return decoder.Decode(r.result["Data"])
+	if err := decoder.Decode(r.result["Data"]); err != nil {
+		return &DecodeError{Body: truncateBody(result), Err: err}
+	}
+	return nil
 }
\ No newline at end of file
@@ -1,81 +1,421 @@
 package rpc_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bolaxy/common"
+	ethTypes "github.com/bolaxy/eth/types"
 	"github.com/davecgh/go-spew/spew"
 
+	"github.com/bolaxytools/tool-sdk"
 	"github.com/bolaxytools/tool-sdk/rpc"
+	"github.com/bolaxytools/tool-sdk/rpc/rpctest"
 )
 
 const (
-	testAddress         = "0xbf0c265f0d1b3df1229f34486b62fee1e99f0d10"
-	testContractAddress = "0x599d7abdb0a289f85aaca706b55d1b96cc07f348"
-	testTxHash = "0xe349b239e5b2fbb8ebe96556c3caa4c2b419f9a51af5e497bba0735c88a48b6d"
+	testAddress = "0xbf0c265f0d1b3df1229f34486b62fee1e99f0d10"
 )
 
 var (
-	client = rpc.Dial("http://192.168.10.189:8082")
+	testBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
 )
 
+// newTestNode start a fake node with a funded key
+func newTestNode(t *testing.T, opts ...rpctest.Option) (*rpctest.Node, *rpc.Client, *sdk.Key) {
+	key, err := sdk.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	node := rpctest.NewNode(opts...)
+	node.SetBalance(key.GetAddress(), testBalance)
+	return node, node.Client(), key
+}
+
+func transfer(t *testing.T, client *rpc.Client, key *sdk.Key, to common.Address, value *big.Int) *rpc.SendTxResult {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := client.SendTransaction(ctx, key, &rpc.SendTxArgs{To: &to, Value: value}, rpc.WithReceiptWait(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("SendTransaction: %v", err)
+	}
+	return res
+}
+
 func TestClient_FetchNonce(t *testing.T) {
-	nonce, err := client.FetchNonce(testAddress)
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	transfer(t, client, key, common.HexToAddress(testAddress), big.NewInt(1))
+
+	nonce, err := client.FetchNonce(key.GetStringAddress())
 	if err != nil {
-		t.Logf("FetchNonce: %v", err)
-		t.FailNow()
+		t.Fatalf("FetchNonce: %v", err)
+	}
+	if nonce != 1 {
+		t.Fatalf("nonce: have %d, want 1", nonce)
 	}
-	t.Logf("nonce: %d", nonce)
 }
 
 func TestClient_FetchChainInfo(t *testing.T) {
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	transfer(t, client, key, common.HexToAddress(testAddress), big.NewInt(1))
+
 	meta, err := client.FetchChainInfo()
 	if err != nil {
-		t.Logf("FetchChainInfo: %v", err)
-		t.FailNow()
+		t.Fatalf("FetchChainInfo: %v", err)
+	}
+	if meta.BlockHeight != "1" {
+		t.Fatalf("last_block_index: have %s, want 1", meta.BlockHeight)
+	}
+	if info, err := meta.Typed(); err != nil || info.BlockHeight != 1 || info.State != rpc.StateBabbling || info.SyncRate != 1 {
+		t.Fatalf("unexpected typed chain info: %+v, %v", info, err)
+	}
+}
+
+func TestChainMeta_Typed(t *testing.T) {
+	info := `{"consensus_events":"4025","consensus_transactions":"230","events_per_second":"1.25","id":"3615552456",` +
+		`"last_block_index":"%s","last_consensus_round":"%s","moniker":"node1","num_peers":"3","round_events":"0",` +
+		`"rounds_per_second":"0.50","state":"%s","sync_rate":"1.00","transaction_pool":"7","type":"babble","undetermined_events":"20"}`
+	height, round, state := "210", "463", "CatchingUp"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Data":`+info+`,"Err":""}`, height, round, state)
+	}))
+	defer server.Close()
+
+	client := rpc.Dial(server.URL, rpc.WithRetry(nil))
+	meta, err := client.FetchChainInfo()
+	if err != nil {
+		t.Fatalf("FetchChainInfo: %v", err)
+	}
+	typed, err := meta.Typed()
+	if err != nil {
+		t.Fatalf("Typed: %v", err)
+	}
+	want := rpc.ChainInfo{
+		EventsNum:          4025,
+		EventRate:          1.25,
+		TransactionsNum:    230,
+		Id:                 3615552456,
+		BlockHeight:        210,
+		ConsensusRound:     463,
+		NodeName:           "node1",
+		PeersNum:           3,
+		RoundEvents:        0,
+		RoundRate:          0.5,
+		State:              rpc.StateCatchingUp,
+		SyncRate:           1,
+		InPoolNum:          7,
+		Type:               "babble",
+		UnDeterminedEvents: 20,
+	}
+	if typed != want {
+		t.Fatalf("typed chain info: have %+v, want %+v", typed, want)
+	}
+	if meta.BlockHeight != "210" || meta.State != "CatchingUp" || typed.State.String() != "CatchingUp" {
+		t.Fatalf("unexpected raw chain info: %+v", meta)
+	}
+
+	// a fresh node has no block and no decided round
+	height, round, state = "-1", "nil", "Gossiping"
+	meta, err = client.FetchChainInfo()
+	if err != nil {
+		t.Fatalf("FetchChainInfo: %v", err)
+	}
+	if typed, err := meta.Typed(); err != nil || typed.BlockHeight != -1 || typed.ConsensusRound != -1 || typed.State != rpc.StateBabbling {
+		t.Fatalf("fresh node chain info: %+v, %v", typed, err)
+	}
+	if blocks, err := client.FetchBlocks(context.Background(), 0, -1); err != nil || len(blocks) != 0 {
+		t.Fatalf("FetchBlocks of fresh node: %d blocks, %v", len(blocks), err)
 	}
 
-	spew.Dump(meta)
+	// the raw strings are still available if a field is malformed
+	height, round = "abc", "463"
+	meta, err = client.FetchChainInfo()
+	if err != nil || meta.BlockHeight != "abc" {
+		t.Fatalf("FetchChainInfo: %v, %v", meta, err)
+	}
+	typed, err = meta.Typed()
+	if err == nil || !strings.Contains(err.Error(), "last_block_index") {
+		t.Fatalf("Typed: have %v, want last_block_index error", err)
+	}
+	if typed.ConsensusRound != 463 || typed.EventsNum != 4025 {
+		t.Fatalf("other fields should be parsed: %+v", typed)
+	}
 }
 
 func TestClient_FetchAccount(t *testing.T) {
-	acc, err := client.FetchAccount(testAddress)
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	acc, err := client.FetchAccount(key.GetStringAddress())
 	if err != nil {
-		t.Logf("FetchAccount: %v", err)
-		t.FailNow()
+		t.Fatalf("FetchAccount: %v", err)
+	}
+	if !strings.EqualFold(acc.Address, key.GetStringAddress()) || acc.Balance.Cmp(testBalance) != 0 || acc.Nonce != 0 {
+		t.Fatalf("unexpected account: %+v", acc)
 	}
-
-	spew.Dump(acc)
 }
 
 func TestClient_FetchBalance(t *testing.T) {
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	to := common.HexToAddress(testAddress)
+	transfer(t, client, key, to, big.NewInt(1e9))
+
+	balance, err := client.FetchBalance(testAddress)
+	if err != nil {
+		t.Fatalf("FetchBalance: %v", err)
+	}
+	if balance.Cmp(big.NewInt(1e9)) != 0 {
+		t.Fatalf("balance: have %v, want 1000000000", balance)
+	}
+}
+
+func TestClient_FetchBlock(t *testing.T) {
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	res := transfer(t, client, key, common.HexToAddress(testAddress), big.NewInt(1))
+
 	blk, err := client.FetchBlock(1)
 	if err != nil {
-		t.Logf("FetchBalance: %v", err)
-		t.FailNow()
+		t.Fatalf("FetchBlock: %v", err)
+	}
+	txs, err := sdk.GetTransactionsFromBlkWithChainID(blk, client.ChainID())
+	if err != nil {
+		t.Fatalf("GetTransactionsFromBlk: %v", err)
+	}
+	if blk.Index() != 1 || len(txs) != 1 || txs[0].Hash != res.TxHash.String() {
+		t.Fatalf("unexpected block: %s", spew.Sdump(blk))
 	}
 
-	spew.Dump(blk)
+	if _, err := client.FetchBlock(2); !rpc.IsNotFound(err) {
+		t.Fatalf("FetchBlock: have %v, want not found", err)
+	}
 }
 
 func TestClient_IsContract(t *testing.T) {
-	chk, err := client.IsContract(testContractAddress)
+	node, client, key := newTestNode(t)
+	defer node.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := client.SendTransaction(ctx, key, &rpc.SendTxArgs{Data: "0x6001600055"}, rpc.WithReceiptWait(10*time.Millisecond))
 	if err != nil {
-		t.Logf("IsContract: %v", err)
-		t.FailNow()
+		t.Fatalf("SendTransaction: %v", err)
+	}
+	if res.ContractAddress == nil || res.Receipt.ContractAddress != *res.ContractAddress {
+		t.Fatalf("unexpected contract address: %s", spew.Sdump(res))
 	}
 
+	chk, err := client.IsContract(res.ContractAddress.String())
+	if err != nil {
+		t.Fatalf("IsContract: %v", err)
+	}
 	if !chk {
-		t.Logf("unexpect value")
-		t.FailNow()
+		t.Fatalf("IsContract: have false, want true")
+	}
+
+	if chk, _ := client.IsContract(testAddress); chk {
+		t.Fatalf("IsContract: have true, want false")
 	}
 }
 
 func TestClient_FetchReceipt(t *testing.T) {
-	receipt, err := client.FetchReceipt(testTxHash)
+	logAddr := common.HexToAddress(testAddress)
+	topic := common.HexToHash("0x01")
+	node, client, key := newTestNode(t, rpctest.WithExecutor(func(from common.Address, tx *ethTypes.Transaction) *rpctest.Execution {
+		return &rpctest.Execution{
+			Status:  ethTypes.ReceiptStatusSuccessful,
+			GasUsed: 30000,
+			Logs:    []*ethTypes.Log{{Address: logAddr, Topics: []common.Hash{topic}, Data: []byte{0xde, 0xad}}},
+		}
+	}))
+	defer node.Close()
+
+	res := transfer(t, client, key, logAddr, big.NewInt(1))
+
+	receipt, err := client.FetchReceipt(res.TxHash.String())
+	if err != nil {
+		t.Fatalf("FetchReceipt: %v", err)
+	}
+	if receipt.TransactionHash != res.TxHash || receipt.From != key.GetAddress() ||
+		receipt.GasUsed != 30000 || receipt.Status != ethTypes.ReceiptStatusSuccessful {
+		t.Fatalf("unexpected receipt: %s", spew.Sdump(receipt))
+	}
+	if len(receipt.Logs) != 1 || receipt.Logs[0].Address != logAddr ||
+		receipt.Logs[0].Topics[0] != topic || !bytes.Equal(receipt.Logs[0].Data, []byte{0xde, 0xad}) {
+		t.Fatalf("unexpected logs: %s", spew.Sdump(receipt.Logs))
+	}
+	if receipt.Logs[0].TxHash != res.TxHash || receipt.Logs[0].BlockNumber != 1 || receipt.Logs[0].Index != 0 {
+		t.Fatalf("unexpected log position: %s", spew.Sdump(receipt.Logs[0]))
+	}
+
+	if _, err := client.FetchReceipt(common.Hash{}.String()); !rpc.IsNotFound(err) {
+		t.Fatalf("FetchReceipt: have %v, want not found", err)
+	}
+}
+
+func TestClient_FetchReceiptLogs(t *testing.T) {
+	receipt := `{"Data":{"transactionHash":"0x5a9e7bf3e9627764b308a0b4e6e875c1197153a9628d98af366bb72ba1bfce5e",` +
+		`"from":"0x17f9ab565f346adb864f2683475fbeebccf52dbb","to":null,"gasUsed":21000,"status":1,"logs":[%s]},"Err":""}`
+	var logs string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, receipt, logs)
+	}))
+	defer server.Close()
+	client := rpc.Dial(server.URL, rpc.WithRetry(nil))
+
+	logJSON := func(data, index string) string {
+		return `{"address":"0x599d7abdb0a289f85aaca706b55d1b96cc07f348","topics":null,"data":` + data +
+			`,"blockNumber":"0x3","transactionHash":"0x5a9e7bf3e9627764b308a0b4e6e875c1197153a9628d98af366bb72ba1bfce5e",` +
+			`"transactionIndex":"0x1","blockHash":"0x00","logIndex":` + index + `,"removed":false}`
+	}
+
+	tests := []struct {
+		name  string
+		logs  string
+		data  [][]byte
+		index []uint
+	}{
+		// "0x123456" is also valid base64, log data is always hex if 0x prefixed
+		{"hex", logJSON(`"0x123456"`, `"0x4"`) + "," + logJSON(`"0x"`, `"0x5"`), [][]byte{{0x12, 0x34, 0x56}, {}}, []uint{4, 5}},
+		{"base64", logJSON(`"3q0="`, `"0x0"`), [][]byte{{0xde, 0xad}}, []uint{0}},
+		{"numbers", logJSON(`""`, `7`), [][]byte{{}}, []uint{7}},
+	}
+	for _, test := range tests {
+		logs = test.logs
+		r, err := client.FetchReceipt("0x5a9e7bf3e9627764b308a0b4e6e875c1197153a9628d98af366bb72ba1bfce5e")
+		if err != nil {
+			t.Fatalf("%s: FetchReceipt: %v", test.name, err)
+		}
+		if len(r.Logs) != len(test.data) {
+			t.Fatalf("%s: %d logs, want %d", test.name, len(r.Logs), len(test.data))
+		}
+		for i, lg := range r.Logs {
+			if !bytes.Equal(lg.Data, test.data[i]) || lg.Index != test.index[i] {
+				t.Fatalf("%s: log %d: data %x, index %d, want %x, %d", test.name, i, lg.Data, lg.Index, test.data[i], test.index[i])
+			}
+			if lg.TxIndex != 1 || lg.BlockNumber != 3 || lg.TxHash != r.TransactionHash {
+				t.Fatalf("%s: log %d: unexpected position %s", test.name, i, spew.Sdump(lg))
+			}
+		}
+	}
+
+	// malformed logs are reported instead of decoded as zero
+	for _, bad := range []string{logJSON(`"0xzz"`, `"0x0"`), logJSON(`"0x00"`, `"0xzz"`)} {
+		logs = bad
+		var decodeErr *rpc.DecodeError
+		if _, err := client.FetchReceipt("0x5a9e7bf3e9627764b308a0b4e6e875c1197153a9628d98af366bb72ba1bfce5e"); !errors.As(err, &decodeErr) {
+			t.Fatalf("FetchReceipt %s: have %v, want DecodeError", bad, err)
+		}
+	}
+}
+
+func TestClient_SendTransaction(t *testing.T) {
+	node, client, key := newTestNode(t, rpctest.WithManualMining())
+	defer node.Close()
+
+	nm := rpc.NewNonceManager(client)
+	to := common.HexToAddress(testAddress)
+	for i := 0; i < 3; i++ {
+		res, err := client.SendTransaction(context.Background(), key, &rpc.SendTxArgs{To: &to, Value: big.NewInt(1)}, rpc.WithNonceManager(nm))
+		if err != nil {
+			t.Fatalf("SendTransaction: %v", err)
+		}
+		if res.Tx.Nonce() != uint64(i) {
+			t.Fatalf("nonce: have %d, want %d", res.Tx.Nonce(), i)
+		}
+	}
+	if node.Pending() != 3 {
+		t.Fatalf("pending: have %d, want 3", node.Pending())
+	}
+
+	// a stale nonce is rejected by node
+	nonce := uint64(0)
+	_, err := client.SendTransaction(context.Background(), key, &rpc.SendTxArgs{To: &to, Nonce: &nonce})
+	if !rpc.IsNonceError(err) {
+		t.Fatalf("SendTransaction: have %v, want nonce error", err)
+	}
+
+	node.Mine()
+	balance, err := client.FetchBalance(testAddress)
+	if err != nil {
+		t.Fatalf("FetchBalance: %v", err)
+	}
+	if balance.Int64() != 3 {
+		t.Fatalf("balance: have %v, want 3", balance)
+	}
+}
+
+func TestClient_DialOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/block/1" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		fmt.Fprintf(w, `{"Data":{"address":"%s","balance":0,"nonce":7,"bytecode":""},"Err":""}`, testAddress)
+	}))
+	defer server.Close()
+
+	c := rpc.Dial(server.URL, rpc.WithHeader("Authorization", "Bearer token"), rpc.WithTimeout(50*time.Millisecond))
+	nonce, err := c.FetchNonce(testAddress)
 	if err != nil {
-		t.Logf("FetchReceipt: %v", err)
-		t.FailNow()
+		t.Fatalf("FetchNonce: %v", err)
+	}
+	if nonce != 7 {
+		t.Fatalf("nonce: have %d, want 7", nonce)
+	}
+
+	if _, err := c.FetchBlock(1); err == nil {
+		t.Fatalf("FetchBlock: expected timeout")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.FetchNonceContext(ctx, testAddress); err == nil {
+		t.Fatalf("FetchNonceContext: expected canceled")
+	}
+
+	// the tls config is applied to a clone of an *http.Transport
+	c = rpc.Dial(server.URL, rpc.WithHeader("Authorization", "Bearer token"),
+		rpc.WithHTTPClient(&http.Client{Transport: &http.Transport{}}), rpc.WithTLSConfig(&tls.Config{}))
+	if _, err := c.FetchNonce(testAddress); err != nil || c.Err() != nil {
+		t.Fatalf("FetchNonce with tls config: %v, %v", err, c.Err())
 	}
 
-	spew.Dump(receipt)
-}
\ No newline at end of file
+	// a custom transport is not replaced
+	replayer, err := rpctest.NewReplayer(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	c = rpc.Dial(server.URL, rpc.WithHTTPClient(&http.Client{Transport: replayer}), rpc.WithProxy(http.ProxyFromEnvironment))
+	if !errors.Is(c.Err(), rpc.ErrCustomTransport) {
+		t.Fatalf("Err: have %v, want %v", c.Err(), rpc.ErrCustomTransport)
+	}
+	if _, err := c.FetchNonce(testAddress); !errors.Is(err, rpc.ErrCustomTransport) {
+		t.Fatalf("FetchNonce: have %v, want %v", err, rpc.ErrCustomTransport)
+	}
+	if _, err := rpctest.DialFixture(server.URL, t.TempDir(), false, rpc.WithTLSConfig(&tls.Config{})); !errors.Is(err, rpc.ErrCustomTransport) {
+		t.Fatalf("DialFixture: have %v, want %v", err, rpc.ErrCustomTransport)
+	}
+}
@@ -0,0 +1,242 @@
+package rpctest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/bolaxytools/tool-sdk/rpc"
+)
+
+// ErrFixtureNotFound no fixture was recorded for the request
+var ErrFixtureNotFound = errors.New("fixture not found")
+
+// fixture the recorded exchanges of one request,
+// a request may be recorded several times, e.g. polling a receipt
+type fixture struct {
+	Method      string      `json:"method"`
+	Path        string      `json:"path"`
+	RequestBody string      `json:"requestBody,omitempty"`
+	Responses   []*exchange `json:"responses"`
+}
+
+type exchange struct {
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body"`
+}
+
+// fixtureName the file name of a request, the host is not part of it
+// so fixtures can be replayed against any address
+func fixtureName(req *http.Request, body []byte) string {
+	path := req.URL.Path
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+
+	name := req.Method + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, path)
+
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		name += "_" + hex.EncodeToString(sum[:4])
+	}
+	return name + ".json"
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return ioutil.ReadAll(req.Body)
+}
+
+// Recorder http.RoundTripper which saves every request and response into a fixture directory.
+// request headers are not saved, so auth tokens do not leak into fixtures.
+type Recorder struct {
+	dir  string
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	fixtures map[string]*fixture
+}
+
+// NewRecorder create recorder saving into dir, requests are sent by next,
+// http.DefaultTransport is used if next is nil.
+// fixtures recorded before in dir are overwritten on the first request of the same name
+func NewRecorder(dir string, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{dir: dir, next: next, fixtures: make(map[string]*fixture)}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(payload))
+
+	if err := r.save(req, body, &exchange{
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(payload),
+	}); err != nil {
+		return nil, errors.Wrap(err, "recorder[save]")
+	}
+	return resp, nil
+}
+
+func (r *Recorder) save(req *http.Request, body []byte, ex *exchange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := fixtureName(req, body)
+	f, ok := r.fixtures[name]
+	if !ok {
+		f = &fixture{Method: req.Method, Path: req.URL.RequestURI(), RequestBody: string(body)}
+		r.fixtures[name] = f
+	}
+	f.Responses = append(f.Responses, ex)
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(r.dir, name), data, 0644)
+}
+
+// Replayer http.RoundTripper which answers requests from a fixture directory without network access.
+// the responses of a request are replayed in the recorded order, the last one is repeated after that.
+type Replayer struct {
+	dir string
+
+	mu     sync.Mutex
+	served map[string]int
+}
+
+// NewReplayer create replayer reading fixtures from dir
+func NewReplayer(dir string) (*Replayer, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "replayer[stat]")
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("replayer: %s is not a directory", dir)
+	}
+	return &Replayer{dir: dir, served: make(map[string]int)}, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fixtureName(req, body)
+	data, err := ioutil.ReadFile(filepath.Join(r.dir, name))
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(ErrFixtureNotFound, "%s %s", req.Method, req.URL.RequestURI())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, errors.Wrapf(err, "replayer[decode %s]", name)
+	}
+	if len(f.Responses) == 0 {
+		return nil, errors.Wrapf(ErrFixtureNotFound, "%s has no responses", name)
+	}
+
+	r.mu.Lock()
+	i := r.served[name]
+	if i < len(f.Responses)-1 {
+		r.served[name]++
+	}
+	r.mu.Unlock()
+
+	ex := f.Responses[i]
+	header := make(http.Header)
+	if ex.ContentType != "" {
+		header.Set("Content-Type", ex.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", ex.StatusCode, http.StatusText(ex.StatusCode)),
+		StatusCode:    ex.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(ex.Body)),
+		ContentLength: int64(len(ex.Body)),
+		Request:       req,
+	}, nil
+}
+
+// DialFixture dial host through a Recorder if record is set, otherwise through a Replayer
+// reading dir, in which case host is not contacted and retries are disabled.
+// it is usually driven by a test flag:
+//
+//	var record = flag.Bool("record", false, "record fixtures from a real node")
+//	client, err := rpctest.DialFixture("http://127.0.0.1:8080", "testdata/fixtures", *record)
+func DialFixture(host, dir string, record bool, opts ...rpc.DialOpt) (*rpc.Client, error) {
+	var transport http.RoundTripper
+	if record {
+		transport = NewRecorder(dir, nil)
+	} else {
+		replayer, err := NewReplayer(dir)
+		if err != nil {
+			return nil, err
+		}
+		transport = replayer
+		opts = append([]rpc.DialOpt{rpc.WithRetry(nil)}, opts...)
+	}
+
+	opts = append(opts, rpc.WithHTTPClient(&http.Client{Transport: transport}))
+	client := rpc.Dial(host, opts...)
+	if err := client.Err(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,71 @@
+package rpctest_test
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/bolaxy/common"
+	"github.com/pkg/errors"
+
+	"github.com/bolaxytools/tool-sdk/rpc"
+	"github.com/bolaxytools/tool-sdk/rpc/rpctest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpctest-fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	node := rpctest.NewNode()
+	addr := common.HexToAddress("0xbf0c265f0d1b3df1229f34486b62fee1e99f0d10")
+	node.SetBalance(addr, big.NewInt(1000))
+
+	recorded, err := rpctest.DialFixture(node.URL(), dir, true)
+	if err != nil {
+		t.Fatalf("DialFixture: %v", err)
+	}
+	if _, err := recorded.FetchBlock(0); err != nil {
+		t.Fatalf("FetchBlock: %v", err)
+	}
+	if _, err := recorded.CallContract(&rpc.SendTxArgs{To: &addr}); err != nil {
+		t.Fatalf("CallContract: %v", err)
+	}
+	for _, balance := range []int64{1000, 2000} {
+		node.SetBalance(addr, big.NewInt(balance))
+		if _, err := recorded.FetchBalance(addr.String()); err != nil {
+			t.Fatalf("FetchBalance: %v", err)
+		}
+	}
+	node.Close()
+
+	replayed, err := rpctest.DialFixture("http://127.0.0.1:1", dir, false)
+	if err != nil {
+		t.Fatalf("DialFixture: %v", err)
+	}
+	blk, err := replayed.FetchBlock(0)
+	if err != nil || blk.Index() != 0 {
+		t.Fatalf("FetchBlock: %v, %v", blk, err)
+	}
+	if _, err := replayed.CallContract(&rpc.SendTxArgs{To: &addr}); err != nil {
+		t.Fatalf("CallContract: %v", err)
+	}
+
+	// responses are replayed in order, then the last one is repeated
+	for _, want := range []int64{1000, 2000, 2000} {
+		balance, err := replayed.FetchBalance(addr.String())
+		if err != nil {
+			t.Fatalf("FetchBalance: %v", err)
+		}
+		if balance.Int64() != want {
+			t.Fatalf("balance: have %v, want %d", balance, want)
+		}
+	}
+
+	if _, err := replayed.FetchBlock(1); !errors.Is(err, rpctest.ErrFixtureNotFound) {
+		t.Fatalf("FetchBlock: have %v, want ErrFixtureNotFound", err)
+	}
+}
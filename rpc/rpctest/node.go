@@ -0,0 +1,484 @@
+// Package rpctest provides an in-process fake bolaxy node for tests.
+//
+// The node serves /info, /block/, /tx/, /rawtx, /account/ and /call from an
+// in-memory chain in the same JSON shapes as a real node, so rpc.Client,
+// rpc.Pool and the block monitor can be tested without network access.
+//
+//	node := rpctest.NewNode()
+//	defer node.Close()
+//	node.SetBalance(key.GetAddress(), big.NewInt(1e18))
+//	client := node.Client()
+package rpctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/hexutil"
+	"github.com/bolaxy/core/types"
+	"github.com/bolaxy/crypto"
+	ethTypes "github.com/bolaxy/eth/types"
+	"github.com/bolaxy/rlp"
+
+	"github.com/bolaxytools/tool-sdk/rpc"
+)
+
+const (
+	transferGas uint64 = 21000
+)
+
+// Execution the result of executing a transaction on the fake node
+type Execution struct {
+	// Status 1 for success, 0 for failure
+	Status uint64
+	// GasUsed gas used by the transaction
+	GasUsed uint64
+	// Logs emitted logs, only Address, Topics and Data are needed,
+	// the block and transaction fields are filled by the node
+	Logs []*ethTypes.Log
+}
+
+// Executor execute a transaction sent by from, after the value has been transferred
+type Executor func(from common.Address, tx *ethTypes.Transaction) *Execution
+
+// CallHandler handle /call requests, return the output of the contract call
+type CallHandler func(msg *rpc.SendTxArgs) ([]byte, error)
+
+// Option options of Node
+type Option func(node *Node)
+
+// WithChainID set the chain id used to verify raw transactions, default is common.ChainID
+func WithChainID(chainID *big.Int) Option {
+	return func(node *Node) {
+		node.chainID = chainID
+	}
+}
+
+// WithExecutor set the executor of transactions, default always succeeds with 21000 gas
+func WithExecutor(executor Executor) Option {
+	return func(node *Node) {
+		node.executor = executor
+	}
+}
+
+// WithCallHandler set the handler of /call, default returns empty output
+func WithCallHandler(handler CallHandler) Option {
+	return func(node *Node) {
+		node.call = handler
+	}
+}
+
+// WithManualMining pack received transactions only when Mine is called,
+// by default every transaction is packed into a new block at once
+func WithManualMining() Option {
+	return func(node *Node) {
+		node.manual = true
+	}
+}
+
+// Node in-process fake bolaxy node
+type Node struct {
+	server   *httptest.Server
+	chainID  *big.Int
+	executor Executor
+	call     CallHandler
+	manual   bool
+
+	mu       sync.Mutex
+	accounts map[common.Address]*account
+	blocks   []*types.Block
+	receipts map[common.Hash]*receipt
+	pending  []*pendingTx
+	known    map[common.Hash]bool
+}
+
+type account struct {
+	balance *big.Int
+	nonce   uint64
+	code    []byte
+}
+
+type pendingTx struct {
+	raw  []byte
+	tx   *ethTypes.Transaction
+	from common.Address
+}
+
+// receipt the receipt json of a real node, the numbers are plain json numbers
+// and the logs are encoded by ethTypes.Log
+type receipt struct {
+	Root              common.Hash     `json:"root"`
+	TransactionHash   common.Hash     `json:"transactionHash"`
+	From              common.Address  `json:"from"`
+	To                *common.Address `json:"to"`
+	GasUsed           uint64          `json:"gasUsed"`
+	CumulativeGasUsed uint64          `json:"cumulativeGasUsed"`
+	ContractAddress   common.Address  `json:"contractAddress"`
+	Logs              []*ethTypes.Log `json:"logs"`
+	LogsBloom         ethTypes.Bloom  `json:"logsBloom"`
+	Status            uint64          `json:"status"`
+}
+
+type response struct {
+	Data interface{}
+	Err  string
+}
+
+// NewNode start a fake node with the genesis block 0
+func NewNode(opts ...Option) *Node {
+	node := &Node{
+		chainID:  common.ChainID,
+		accounts: make(map[common.Address]*account),
+		receipts: make(map[common.Hash]*receipt),
+		known:    make(map[common.Hash]bool),
+	}
+	for _, opt := range opts {
+		opt(node)
+	}
+
+	node.blocks = append(node.blocks, newBlock(0, nil))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/info", node.handleInfo)
+	mux.HandleFunc("/block/", node.handleBlock)
+	mux.HandleFunc("/tx/", node.handleReceipt)
+	mux.HandleFunc("/rawtx", node.handleRawTx)
+	mux.HandleFunc("/account/", node.handleAccount)
+	mux.HandleFunc("/call", node.handleCall)
+	node.server = httptest.NewServer(mux)
+	return node
+}
+
+// URL the base url of the node
+func (n *Node) URL() string {
+	return n.server.URL
+}
+
+// Close shut down the node
+func (n *Node) Close() {
+	n.server.Close()
+}
+
+// Client dial the node, the chain id of the node is used
+func (n *Node) Client(opts ...rpc.DialOpt) *rpc.Client {
+	return rpc.Dial(n.URL(), append([]rpc.DialOpt{rpc.WithChainID(n.chainID)}, opts...)...)
+}
+
+// SetBalance set the balance of address
+func (n *Node) SetBalance(address common.Address, balance *big.Int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.account(address).balance = new(big.Int).Set(balance)
+}
+
+// SetCode set the contract code of address
+func (n *Node) SetCode(address common.Address, code []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.account(address).code = common.CopyBytes(code)
+}
+
+// BlockHeight the index of the last block
+func (n *Node) BlockHeight() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return len(n.blocks) - 1
+}
+
+// Pending the number of transactions waiting to be packed
+func (n *Node) Pending() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return len(n.pending)
+}
+
+// Mine pack the pending transactions into a new block, return its index
+func (n *Node) Mine() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.mine()
+}
+
+// AddBlock append a block with the given raw transactions without executing them,
+// it can be used to feed blocks that are hard to produce by sending transactions
+func (n *Node) AddBlock(txs ...[]byte) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	index := len(n.blocks)
+	n.blocks = append(n.blocks, newBlock(index, txs))
+	return index
+}
+
+func (n *Node) account(address common.Address) *account {
+	acc, ok := n.accounts[address]
+	if !ok {
+		acc = &account{balance: new(big.Int)}
+		n.accounts[address] = acc
+	}
+	return acc
+}
+
+func newBlock(index int, txs [][]byte) *types.Block {
+	if txs == nil {
+		txs = [][]byte{}
+	}
+	return &types.Block{
+		Body: types.BlockBody{
+			Index:         index,
+			RoundReceived: index,
+			StateHash:     crypto.Keccak256([]byte(strconv.Itoa(index))),
+			PeersHash:     []byte{},
+			Transactions:  txs,
+		},
+		Signatures: make(map[string]string),
+	}
+}
+
+func (n *Node) mine() int {
+	index := len(n.blocks)
+	blockHash := common.BytesToHash(crypto.Keccak256([]byte(strconv.Itoa(index))))
+
+	txs := make([][]byte, 0, len(n.pending))
+	var cumulativeGas uint64
+	var logIndex uint
+	for i, p := range n.pending {
+		txs = append(txs, p.raw)
+
+		res := n.execute(p.from, p.tx)
+		cumulativeGas += res.GasUsed
+
+		r := &receipt{
+			TransactionHash:   p.tx.Hash(),
+			From:              p.from,
+			To:                p.tx.To(),
+			GasUsed:           res.GasUsed,
+			CumulativeGasUsed: cumulativeGas,
+			Logs:              make([]*ethTypes.Log, 0, len(res.Logs)),
+			Status:            res.Status,
+		}
+		if p.tx.To() == nil {
+			r.ContractAddress = crypto.CreateAddress(p.from, p.tx.Nonce())
+		}
+		for _, lg := range res.Logs {
+			l := *lg
+			l.BlockNumber = uint64(index)
+			l.BlockHash = blockHash
+			l.TxHash = p.tx.Hash()
+			l.TxIndex = uint(i)
+			l.Index = logIndex
+			logIndex++
+			r.Logs = append(r.Logs, &l)
+		}
+		r.LogsBloom = ethTypes.BytesToBloom(ethTypes.LogsBloom(r.Logs).Bytes())
+		n.receipts[p.tx.Hash()] = r
+	}
+
+	n.pending = nil
+	n.blocks = append(n.blocks, newBlock(index, txs))
+	return index
+}
+
+// execute transfer value, create contract and call the executor
+func (n *Node) execute(from common.Address, tx *ethTypes.Transaction) *Execution {
+	sender := n.account(from)
+	if sender.balance.Cmp(tx.Value()) < 0 {
+		return &Execution{Status: ethTypes.ReceiptStatusFailed, GasUsed: transferGas}
+	}
+
+	sender.balance.Sub(sender.balance, tx.Value())
+	to := crypto.CreateAddress(from, tx.Nonce())
+	if tx.To() != nil {
+		to = *tx.To()
+	} else {
+		n.account(to).code = tx.Data()
+	}
+	recipient := n.account(to)
+	recipient.balance.Add(recipient.balance, tx.Value())
+
+	if n.executor != nil {
+		if res := n.executor(from, tx); res != nil {
+			return res
+		}
+	}
+	return &Execution{Status: ethTypes.ReceiptStatusSuccessful, GasUsed: transferGas}
+}
+
+func (n *Node) handleInfo(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	height := len(n.blocks) - 1
+	pending := len(n.pending)
+	n.mu.Unlock()
+
+	writeData(w, map[string]string{
+		"consensus_events":       strconv.Itoa(height * 10),
+		"consensus_transactions": strconv.Itoa(len(n.receipts)),
+		"events_per_second":      "0.00",
+		"id":                     "1",
+		"last_block_index":       strconv.Itoa(height),
+		"last_consensus_round":   strconv.Itoa(height),
+		"moniker":                "rpctest",
+		"num_peers":              "1",
+		"round_events":           "0",
+		"rounds_per_second":      "0.00",
+		"state":                  "Babbling",
+		"sync_rate":              "1.00",
+		"transaction_pool":       strconv.Itoa(pending),
+		"type":                   "babble",
+		"undetermined_events":    "0",
+	})
+}
+
+func (n *Node) handleBlock(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/block/")
+	index, err := strconv.Atoi(key)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, fmt.Sprintf("invalid block index %s", key))
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if index < 0 || index >= len(n.blocks) {
+		writeErr(w, http.StatusOK, fmt.Sprintf("block %d not found", index))
+		return
+	}
+	writeData(w, n.blocks[index])
+}
+
+func (n *Node) handleReceipt(w http.ResponseWriter, r *http.Request) {
+	hash := common.HexToHash(strings.TrimPrefix(r.URL.Path, "/tx/"))
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	receipt, ok := n.receipts[hash]
+	if !ok {
+		writeErr(w, http.StatusOK, fmt.Sprintf("receipt %s not found", hash.String()))
+		return
+	}
+	writeData(w, receipt)
+}
+
+func (n *Node) handleAccount(w http.ResponseWriter, r *http.Request) {
+	address := strings.TrimPrefix(r.URL.Path, "/account/")
+	if !common.IsHexAddress(address) {
+		writeErr(w, http.StatusBadRequest, fmt.Sprintf("invalid address %s", address))
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	addr := common.HexToAddress(address)
+	acc := n.account(addr)
+	code := ""
+	if len(acc.code) > 0 {
+		code = hexutil.Encode(acc.code)
+	}
+	writeData(w, map[string]interface{}{
+		"address":  addr.String(),
+		"balance":  acc.balance,
+		"nonce":    acc.nonce,
+		"bytecode": code,
+	})
+}
+
+func (n *Node) handleRawTx(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	raw := common.FromHex(strings.TrimSpace(string(body)))
+	tx := new(ethTypes.Transaction)
+	if err := rlp.DecodeBytes(raw, tx); err != nil {
+		writeErr(w, http.StatusBadRequest, fmt.Sprintf("decode tx: %v", err))
+		return
+	}
+
+	from, err := ethTypes.Sender(ethTypes.NewEIP155Signer(n.chainID), tx)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, fmt.Sprintf("invalid sender: %v", err))
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.known[tx.Hash()] {
+		writeErr(w, http.StatusOK, fmt.Sprintf("already known transaction %s", tx.Hash().String()))
+		return
+	}
+
+	acc := n.account(from)
+	if tx.Nonce() < acc.nonce {
+		writeErr(w, http.StatusOK, fmt.Sprintf("nonce too low: have %d, want %d", tx.Nonce(), acc.nonce))
+		return
+	}
+	if tx.Nonce() > acc.nonce {
+		writeErr(w, http.StatusOK, fmt.Sprintf("nonce too high: have %d, want %d", tx.Nonce(), acc.nonce))
+		return
+	}
+
+	acc.nonce++
+	n.known[tx.Hash()] = true
+	n.pending = append(n.pending, &pendingTx{raw: raw, tx: tx, from: from})
+
+	contractAddr := ""
+	if tx.To() == nil {
+		contractAddr = crypto.CreateAddress(from, tx.Nonce()).String()
+	}
+	if !n.manual {
+		n.mine()
+	}
+
+	writeData(w, map[string]string{
+		"txHash":       tx.Hash().String(),
+		"contractAddr": contractAddr,
+	})
+}
+
+func (n *Node) handleCall(w http.ResponseWriter, r *http.Request) {
+	var msg rpc.SendTxArgs
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var output []byte
+	if n.call != nil {
+		var err error
+		if output, err = n.call(&msg); err != nil {
+			writeErr(w, http.StatusOK, err.Error())
+			return
+		}
+	}
+	writeData(w, map[string]string{"data": hexutil.Encode(output)})
+}
+
+func writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&response{Data: data})
+}
+
+func writeErr(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&response{Err: msg})
+}
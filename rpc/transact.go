@@ -0,0 +1,218 @@
+package rpc
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/hexutil"
+	ethTypes "github.com/bolaxy/eth/types"
+	"github.com/pkg/errors"
+
+	"github.com/bolaxytools/tool-sdk"
+)
+
+var (
+	defaultGas          uint64 = 100000
+	defaultPollInterval        = time.Second
+)
+
+// SendOpt options of SendTransaction
+type SendOpt func(opts *sendOpts)
+
+type sendOpts struct {
+	wait         bool
+	pollInterval time.Duration
+	nonces       *NonceManager
+}
+
+// WithReceiptWait block until the receipt of the transaction is available
+// or the context expires, fetching the receipt every pollInterval
+func WithReceiptWait(pollInterval time.Duration) SendOpt {
+	return func(opts *sendOpts) {
+		opts.wait = true
+		opts.pollInterval = pollInterval
+	}
+}
+
+// WithNonceManager take the nonce from the nonce manager instead of fetching it from node,
+// the nonce is released if the transaction provably never reached node, e.g. signing failed
+// or the connection was refused, the nonce manager resyncs on any other failure of submitting
+func WithNonceManager(nonces *NonceManager) SendOpt {
+	return func(opts *sendOpts) {
+		opts.nonces = nonces
+	}
+}
+
+// SendTxResult the result of SendTransaction
+type SendTxResult struct {
+	// TxHash hash of the submitted transaction
+	TxHash common.Hash
+	// ContractAddress the created contract address, nil if not a contract creation
+	ContractAddress *common.Address
+	// Tx the signed transaction
+	Tx *ethTypes.Transaction
+	// Receipt the transaction receipt, nil if not waiting for it
+	Receipt *JsonReceipt
+}
+
+// Success the transaction has been executed successfully
+func (r *SendTxResult) Success() bool {
+	return r.Receipt != nil && r.Receipt.Status == ethTypes.ReceiptStatusSuccessful
+}
+
+// SendTransaction fill the nonce and defaults of args, sign it with signer and submit.
+// args.From must be empty or the signer address, args.Data is hex encoded,
+// args.Gas is 100000 if not set.
+// with WithReceiptWait, it blocks until the receipt appears or ctx is done.
+func (c *Client) SendTransaction(ctx context.Context, signer sdk.Signer, args *SendTxArgs, opts ...SendOpt) (*SendTxResult, error) {
+	return sendTransaction(ctx, c, signer, args, opts...)
+}
+
+// WaitReceipt fetch the receipt of txhash every pollInterval until it is available or ctx is done
+// it keeps polling on not found errors before the tx is packed and on retryable errors of a reachable node,
+// any other error, e.g. a node error or a refused connection, is returned at once
+func (c *Client) WaitReceipt(ctx context.Context, txhash common.Hash, pollInterval time.Duration) (*JsonReceipt, error) {
+	return waitReceipt(ctx, c, txhash, pollInterval)
+}
+
+func sendTransaction(ctx context.Context, c API, signer sdk.Signer, args *SendTxArgs, opts ...SendOpt) (*SendTxResult, error) {
+	options := &sendOpts{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	from := signer.GetAddress()
+	if args.From != (common.Address{}) && args.From != from {
+		return nil, errors.Errorf("sendTransaction: from %s mismatch signer %s", args.From.String(), from.String())
+	}
+
+	builder, err := txBuilder(args, c.ChainID())
+	if err != nil {
+		return nil, errors.Wrap(err, "sendTransaction")
+	}
+
+	var nonce uint64
+	managed := !builder.HasNonce() && options.nonces != nil
+	if !builder.HasNonce() {
+		if managed {
+			nonce, err = options.nonces.NextContext(ctx, from)
+		} else {
+			nonce, err = c.FetchNonceContext(ctx, from.String())
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "sendTransaction")
+		}
+		builder.Nonce(nonce)
+	}
+
+	tx, raw, err := builder.Sign(signer)
+	if err != nil {
+		if managed {
+			options.nonces.Release(from, nonce)
+		}
+		return nil, errors.Wrap(err, "sendTransaction[sign]")
+	}
+
+	res, err := c.TransactContext(ctx, raw)
+	if err != nil {
+		if managed {
+			// the nonce can be reused only if the tx never reached node,
+			// otherwise node may have accepted it, so the nonce is synced again
+			if isUnsent(err) {
+				options.nonces.Release(from, nonce)
+			} else {
+				options.nonces.Done(from, nonce)
+				options.nonces.ResyncContext(ctx, from)
+			}
+		}
+		return nil, errors.Wrap(err, "sendTransaction")
+	}
+	if managed {
+		options.nonces.Done(from, nonce)
+	}
+
+	result := &SendTxResult{TxHash: tx.Hash(), Tx: tx}
+	if res.TxHash != "" {
+		result.TxHash = common.HexToHash(res.TxHash)
+	}
+	if common.IsHexAddress(res.ContractAddr) {
+		if addr := common.HexToAddress(res.ContractAddr); addr != (common.Address{}) {
+			result.ContractAddress = &addr
+		}
+	}
+
+	if !options.wait {
+		return result, nil
+	}
+
+	result.Receipt, err = waitReceipt(ctx, c, result.TxHash, options.pollInterval)
+	if err != nil {
+		return result, errors.Wrap(err, "sendTransaction")
+	}
+	return result, nil
+}
+
+func waitReceipt(ctx context.Context, c API, txhash common.Hash, pollInterval time.Duration) (*JsonReceipt, error) {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := c.FetchReceiptContext(ctx, txhash.String())
+		if err == nil {
+			return receipt, nil
+		}
+
+		if !IsNotFound(err) && (!IsRetryable(err) || isUnsent(err)) {
+			return nil, errors.Wrapf(err, "waitReceipt[%s]", txhash.String())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "waitReceipt[%s]: %v", txhash.String(), err)
+		case <-ticker.C:
+		}
+	}
+}
+
+func txBuilder(args *SendTxArgs, chainID *big.Int) (*sdk.TxBuilder, error) {
+	builder := sdk.NewTxBuilder().
+		Value(args.Value).
+		GasPrice(args.GasPrice).
+		FromChainID(args.FromChainid).
+		ToChainID(args.ToChainid).
+		ChainID(chainID)
+
+	if args.To != nil {
+		builder.To(*args.To)
+	}
+	if args.Nonce != nil {
+		builder.Nonce(*args.Nonce)
+	}
+	if args.FromTxhash != nil {
+		builder.FromTxHash(*args.FromTxhash)
+	}
+	if args.TxType != 0 {
+		builder.TxType(args.TxType)
+	}
+
+	gas := args.Gas
+	if gas == 0 {
+		gas = defaultGas
+	}
+	builder.Gas(gas)
+
+	if args.Data != "" {
+		data, err := hexutil.Decode(args.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode data")
+		}
+		builder.Data(data)
+	}
+	return builder, nil
+}
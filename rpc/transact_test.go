@@ -0,0 +1,207 @@
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bolaxy/common"
+	ethTypes "github.com/bolaxy/eth/types"
+	"github.com/bolaxy/rlp"
+
+	"github.com/bolaxytools/tool-sdk"
+	"github.com/bolaxytools/tool-sdk/rpc"
+)
+
+// rawTxServer a node serving /account/ with nonce and keeping the raw txs posted to /rawtx
+type rawTxServer struct {
+	*httptest.Server
+
+	nonce   uint64
+	fetched int32
+
+	mu  sync.Mutex
+	txs []*ethTypes.Transaction
+}
+
+func newRawTxServer(t *testing.T, nonce uint64) *rawTxServer {
+	s := &rawTxServer{nonce: nonce}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/account/"):
+			atomic.AddInt32(&s.fetched, 1)
+			fmt.Fprintf(w, `{"Data":{"address":"%s","balance":0,"nonce":%d,"bytecode":""},"Err":""}`,
+				strings.TrimPrefix(r.URL.Path, "/account/"), s.nonce)
+		case r.URL.Path == "/rawtx":
+			body, _ := ioutil.ReadAll(r.Body)
+			tx := new(ethTypes.Transaction)
+			if err := rlp.DecodeBytes(common.FromHex(string(body)), tx); err != nil {
+				t.Errorf("decode raw tx: %v", err)
+			}
+			s.mu.Lock()
+			s.txs = append(s.txs, tx)
+			s.mu.Unlock()
+			fmt.Fprintf(w, `{"Data":{"txHash":"%s","contractAddr":""},"Err":""}`, tx.Hash().String())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return s
+}
+
+func (s *rawTxServer) lastTx(t *testing.T) *ethTypes.Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.txs) == 0 {
+		t.Fatalf("no tx is sent")
+	}
+	return s.txs[len(s.txs)-1]
+}
+
+func TestSendTransaction_Defaults(t *testing.T) {
+	server := newRawTxServer(t, 7)
+	defer server.Close()
+
+	key, err := sdk.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	chainID := big.NewInt(99)
+	client := rpc.Dial(server.URL, rpc.WithChainID(chainID))
+	to := common.HexToAddress(testAddress)
+
+	// the key signs for another chain
+	if _, err := client.SendTransaction(context.Background(), key, &rpc.SendTxArgs{To: &to}); err == nil || !strings.Contains(err.Error(), "sign") {
+		t.Fatalf("SendTransaction: have %v, want sign error", err)
+	}
+	key.SetChainID(chainID)
+
+	// nonce is fetched from node and gas defaults to 100000
+	res, err := client.SendTransaction(context.Background(), key, &rpc.SendTxArgs{To: &to, Value: big.NewInt(5), Data: "0x0102"})
+	if err != nil {
+		t.Fatalf("SendTransaction: %v", err)
+	}
+	tx := server.lastTx(t)
+	if tx.Nonce() != 7 || tx.Gas() != 100000 || tx.Value().Int64() != 5 || *tx.To() != to || !bytes.Equal(tx.Data(), []byte{1, 2}) {
+		t.Fatalf("unexpected tx: nonce %d, gas %d, value %v, to %s, data %x", tx.Nonce(), tx.Gas(), tx.Value(), tx.To().String(), tx.Data())
+	}
+	if res.TxHash != tx.Hash() || res.Tx.Hash() != tx.Hash() || res.Receipt != nil || res.ContractAddress != nil {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	// the tx is signed by the key for the chain of the client
+	from, err := ethTypes.Sender(ethTypes.NewEIP155Signer(chainID), tx)
+	if err != nil || from != key.GetAddress() {
+		t.Fatalf("sender: have %s, %v, want %s", from.String(), err, key.GetStringAddress())
+	}
+
+	// explicit nonce and gas are kept, the account is not fetched
+	fetched := atomic.LoadInt32(&server.fetched)
+	nonce := uint64(3)
+	if _, err := client.SendTransaction(context.Background(), key, &rpc.SendTxArgs{To: &to, Nonce: &nonce, Gas: 50000}); err != nil {
+		t.Fatalf("SendTransaction: %v", err)
+	}
+	if tx := server.lastTx(t); tx.Nonce() != 3 || tx.Gas() != 50000 {
+		t.Fatalf("unexpected tx: nonce %d, gas %d", tx.Nonce(), tx.Gas())
+	}
+	if atomic.LoadInt32(&server.fetched) != fetched {
+		t.Fatalf("account is fetched with an explicit nonce")
+	}
+
+	// args.From must be the signer
+	other := common.HexToAddress("0x599d7abdb0a289f85aaca706b55d1b96cc07f348")
+	if _, err := client.SendTransaction(context.Background(), key, &rpc.SendTxArgs{From: other, To: &to}); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Fatalf("SendTransaction: have %v, want from mismatch", err)
+	}
+	if _, err := client.SendTransaction(context.Background(), key, &rpc.SendTxArgs{To: &to, Data: "0xzz"}); err == nil {
+		t.Fatalf("SendTransaction: invalid data should fail")
+	}
+}
+
+func TestWaitReceipt(t *testing.T) {
+	const hash = "0x5a9e7bf3e9627764b308a0b4e6e875c1197153a9628d98af366bb72ba1bfce5e"
+	var (
+		polls   int32
+		readyAt int32 = 3
+		broken  int32
+		failing int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case atomic.LoadInt32(&failing) != 0:
+			atomic.AddInt32(&polls, 1)
+			w.WriteHeader(int(atomic.LoadInt32(&failing)))
+			fmt.Fprint(w, `{"Data":null,"Err":"internal error"}`)
+		case atomic.LoadInt32(&broken) == 1:
+			fmt.Fprint(w, `{"Data":{"transactionHash":"`+hash+`","gasUsed":"0xzz"},"Err":""}`)
+		case atomic.AddInt32(&polls, 1) < atomic.LoadInt32(&readyAt):
+			fmt.Fprintf(w, `{"Data":null,"Err":"receipt %s not found"}`, hash)
+		default:
+			fmt.Fprint(w, `{"Data":{"transactionHash":"`+hash+`","gasUsed":21000,"status":1,"logs":[]},"Err":""}`)
+		}
+	}))
+	defer server.Close()
+	client := rpc.Dial(server.URL, rpc.WithRetry(nil))
+
+	// not found is polled until the receipt is available
+	receipt, err := client.WaitReceipt(context.Background(), common.HexToHash(hash), time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitReceipt: %v", err)
+	}
+	if receipt.GasUsed != 21000 || atomic.LoadInt32(&polls) != 3 {
+		t.Fatalf("unexpected receipt: gas %d after %d polls", receipt.GasUsed, atomic.LoadInt32(&polls))
+	}
+
+	// the receipt never appears
+	atomic.StoreInt32(&readyAt, 1<<30)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.WaitReceipt(ctx, common.HexToHash(hash), time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitReceipt: have %v, want deadline exceeded", err)
+	}
+
+	// a malformed receipt is not retried
+	atomic.StoreInt32(&broken, 1)
+	var decodeErr *rpc.DecodeError
+	if _, err := client.WaitReceipt(context.Background(), common.HexToHash(hash), time.Millisecond); !errors.As(err, &decodeErr) {
+		t.Fatalf("WaitReceipt: have %v, want DecodeError", err)
+	}
+
+	atomic.StoreInt32(&broken, 0)
+
+	// a node error is returned at once
+	atomic.StoreInt32(&failing, http.StatusInternalServerError)
+	atomic.StoreInt32(&polls, 0)
+	var nodeErr *rpc.NodeError
+	if _, err := client.WaitReceipt(context.Background(), common.HexToHash(hash), time.Millisecond); !errors.As(err, &nodeErr) {
+		t.Fatalf("WaitReceipt: have %v, want NodeError", err)
+	}
+	if polls := atomic.LoadInt32(&polls); polls != 1 {
+		t.Fatalf("node error polled %d times, want 1", polls)
+	}
+
+	// an unavailable node is polled until ctx is done
+	atomic.StoreInt32(&failing, http.StatusServiceUnavailable)
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.WaitReceipt(ctx, common.HexToHash(hash), time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitReceipt: have %v, want deadline exceeded", err)
+	}
+
+	// a refused connection is returned at once
+	server.Close()
+	if _, err := client.WaitReceipt(context.Background(), common.HexToHash(hash), time.Millisecond); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitReceipt: have %v, want connection error", err)
+	}
+}
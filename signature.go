@@ -0,0 +1,79 @@
+package sdk
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/crypto"
+)
+
+const (
+	signatureLength  = 65
+	recoveryIDOffset = 64
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// TextHash 计算 personal_sign 格式的消息哈希
+// keccak256("\x19Ethereum Signed Message:\n" + len(message) + message)
+func TextHash(message []byte) []byte {
+	msg := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	return crypto.Keccak256([]byte(msg))
+}
+
+// SignHash 对32字节哈希签名，返回 [R || S || V] 格式的签名，V 为 0 或 1
+func (k *Key) SignHash(hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, k.PK)
+}
+
+// SignMessage 按 personal_sign 格式对消息签名，V 为 27 或 28，
+// 与 web3 的 eth_sign / personal_sign 结果一致
+func (k *Key) SignMessage(message []byte) ([]byte, error) {
+	sig, err := k.SignHash(TextHash(message))
+	if err != nil {
+		return nil, err
+	}
+
+	sig[recoveryIDOffset] += 27
+	return sig, nil
+}
+
+// RecoverHashAddress 由哈希和签名恢复签名者地址，V 可以是 0/1 或 27/28
+func RecoverHashAddress(hash, sig []byte) (common.Address, error) {
+	if len(sig) != signatureLength {
+		return common.Address{}, errors.Wrapf(ErrInvalidSignature, "wrong size %d", len(sig))
+	}
+
+	rsv := make([]byte, signatureLength)
+	copy(rsv, sig)
+	if rsv[recoveryIDOffset] >= 27 {
+		rsv[recoveryIDOffset] -= 27
+	}
+	if rsv[recoveryIDOffset] > 1 {
+		return common.Address{}, errors.Wrap(ErrInvalidSignature, "invalid recovery id")
+	}
+
+	pk, err := crypto.SigToPub(hash, rsv)
+	if err != nil {
+		return common.Address{}, errors.Wrap(ErrInvalidSignature, err.Error())
+	}
+
+	return crypto.PubkeyToAddress(*pk), nil
+}
+
+// RecoverAddress 由 personal_sign 格式的消息和签名恢复签名者地址
+func RecoverAddress(message, sig []byte) (common.Address, error) {
+	return RecoverHashAddress(TextHash(message), sig)
+}
+
+// VerifySignature 校验消息签名是否由指定地址签发
+func VerifySignature(address common.Address, message, sig []byte) bool {
+	signer, err := RecoverAddress(message, sig)
+	if err != nil {
+		return false
+	}
+
+	return signer == address
+}
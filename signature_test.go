@@ -0,0 +1,50 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/hexutil"
+)
+
+func TestSignMessage(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	msg := []byte("hello bolaxy")
+	sig, err := key.SignMessage(msg)
+	if err != nil {
+		t.Fatalf("SignMessage: %v", err)
+	}
+
+	if v := sig[recoveryIDOffset]; v != 27 && v != 28 {
+		t.Fatalf("unexpected recovery id %d", v)
+	}
+
+	if !VerifySignature(key.GetAddress(), msg, sig) {
+		t.Fatalf("VerifySignature: signature not verified")
+	}
+	if VerifySignature(key.GetAddress(), []byte("hello"), sig) {
+		t.Fatalf("VerifySignature: verified with wrong message")
+	}
+
+	if _, err := RecoverAddress(msg, sig[:64]); err == nil {
+		t.Fatalf("RecoverAddress: expected error with short signature")
+	}
+}
+
+func TestRecoverAddress(t *testing.T) {
+	// web3.eth.accounts.sign("Some data", privateKey 0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318)
+	sig := hexutil.MustDecode("0xb91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c")
+	want := common.HexToAddress("0x2c7536E3605D9C16a7a3D7b1898e529396a65c23")
+
+	have, err := RecoverAddress([]byte("Some data"), sig)
+	if err != nil {
+		t.Fatalf("RecoverAddress: %v", err)
+	}
+	if have != want {
+		t.Fatalf("address mismatch: have %s, want %s", have.String(), want.String())
+	}
+}
@@ -0,0 +1,293 @@
+package sdk
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/hexutil"
+	"github.com/bolaxy/eth/types"
+	"github.com/bolaxy/rlp"
+)
+
+const (
+	signerAddressUrl  = "/address"
+	signerSignTxUrl   = "/signTx"
+	signerSignHashUrl = "/signHash"
+)
+
+// Signer 签名者。Key 为内存中的实现，RemoteSigner 将签名请求转发给独立的签名服务。
+type Signer interface {
+	// GetAddress 签名者账户地址
+	GetAddress() common.Address
+	// SignTx 对交易签名，返回签名后的交易
+	SignTx(tx *types.Transaction) (*types.Transaction, error)
+	// SignHash 对32字节哈希签名，返回 [R || S || V] 格式的签名，V 为 0 或 1
+	SignHash(hash []byte) ([]byte, error)
+}
+
+var _ Signer = (*Key)(nil)
+
+type signerAddressRes struct {
+	Address common.Address `json:"address"`
+}
+
+type signerTxMsg struct {
+	Tx hexutil.Bytes `json:"tx"`
+}
+
+type signerHashReq struct {
+	Hash hexutil.Bytes `json:"hash"`
+}
+
+type signerHashRes struct {
+	Signature hexutil.Bytes `json:"signature"`
+}
+
+type signerErrRes struct {
+	Error string `json:"error"`
+}
+
+// RemoteSignerOpt options of RemoteSigner
+type RemoteSignerOpt func(rs *RemoteSigner)
+
+// WithSignerHTTPClient 设置请求签名服务使用的 http.Client
+func WithSignerHTTPClient(client *http.Client) RemoteSignerOpt {
+	return func(rs *RemoteSigner) {
+		rs.client = client
+	}
+}
+
+// WithSignerToken 设置访问签名服务的 Bearer token
+func WithSignerToken(token string) RemoteSignerOpt {
+	return func(rs *RemoteSigner) {
+		rs.token = token
+	}
+}
+
+// RemoteSigner 通过 HTTP 调用签名服务（见 NewSignerHandler）的签名者。
+// 签名结果会在本地校验签名者地址，防止签名服务返回错误的数据。
+type RemoteSigner struct {
+	url     string
+	token   string
+	client  *http.Client
+	address common.Address
+}
+
+// NewRemoteSigner 连接签名服务并获取其账户地址
+func NewRemoteSigner(url string, opts ...RemoteSignerOpt) (*RemoteSigner, error) {
+	rs := &RemoteSigner{url: strings.TrimSuffix(url, "/")}
+	for _, opt := range opts {
+		opt(rs)
+	}
+
+	if rs.client == nil {
+		rs.client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	var res signerAddressRes
+	if err := rs.call(http.MethodGet, signerAddressUrl, nil, &res); err != nil {
+		return nil, errors.Wrap(err, "remote signer[address]")
+	}
+	rs.address = res.Address
+	return rs, nil
+}
+
+// GetAddress 签名服务的账户地址
+func (rs *RemoteSigner) GetAddress() common.Address {
+	return rs.address
+}
+
+// SignTx 请求签名服务对交易签名
+func (rs *RemoteSigner) SignTx(tx *types.Transaction) (*types.Transaction, error) {
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "remote signer[encode tx]")
+	}
+
+	var res signerTxMsg
+	if err := rs.call(http.MethodPost, signerSignTxUrl, &signerTxMsg{Tx: raw}, &res); err != nil {
+		return nil, errors.Wrap(err, "remote signer[signTx]")
+	}
+
+	signed := new(types.Transaction)
+	if err := rlp.DecodeBytes(res.Tx, signed); err != nil {
+		return nil, errors.Wrap(err, "remote signer[decode tx]")
+	}
+
+	signer := types.NewEIP155Signer(signed.ChainId())
+	if signer.Hash(signed) != signer.Hash(tx) {
+		return nil, errors.New("remote signer[signTx]: signed transaction content mismatch")
+	}
+
+	from, err := types.Sender(signer, signed)
+	if err != nil {
+		return nil, errors.Wrap(err, "remote signer[sender]")
+	}
+	if from != rs.address {
+		return nil, fmt.Errorf("remote signer[signTx]: signed by %s, want %s", from.String(), rs.address.String())
+	}
+	return signed, nil
+}
+
+// SignHash 请求签名服务对32字节哈希签名
+func (rs *RemoteSigner) SignHash(hash []byte) ([]byte, error) {
+	var res signerHashRes
+	if err := rs.call(http.MethodPost, signerSignHashUrl, &signerHashReq{Hash: hash}, &res); err != nil {
+		return nil, errors.Wrap(err, "remote signer[signHash]")
+	}
+
+	from, err := RecoverHashAddress(hash, res.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "remote signer[recover]")
+	}
+	if from != rs.address {
+		return nil, fmt.Errorf("remote signer[signHash]: signed by %s, want %s", from.String(), rs.address.String())
+	}
+	return res.Signature, nil
+}
+
+func (rs *RemoteSigner) call(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, rs.url+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if rs.token != "" {
+		req.Header.Set("Authorization", "Bearer "+rs.token)
+	}
+
+	resp, err := rs.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	payload, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var res signerErrRes
+		if json.Unmarshal(payload, &res) == nil && res.Error != "" {
+			return fmt.Errorf("status %d: %s", resp.StatusCode, res.Error)
+		}
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	return json.Unmarshal(payload, out)
+}
+
+// NewSignerHandler 签名服务的参考实现，将 Signer 以 HTTP 接口提供给 RemoteSigner 使用
+//
+//	GET  /address   {"address": "0x..."}
+//	POST /signTx    {"tx": "0x<rlp>"} -> {"tx": "0x<signed rlp>"}
+//	POST /signHash  {"hash": "0x..."} -> {"signature": "0x..."}
+//
+// token 不为空时要求请求携带 Authorization: Bearer <token>
+func NewSignerHandler(signer Signer, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(signerAddressUrl, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeSignerErr(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeSignerRes(w, &signerAddressRes{Address: signer.GetAddress()})
+	})
+	mux.HandleFunc(signerSignTxUrl, func(w http.ResponseWriter, r *http.Request) {
+		var req signerTxMsg
+		if !readSignerReq(w, r, &req) {
+			return
+		}
+
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(req.Tx, tx); err != nil {
+			writeSignerErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		signed, err := signer.SignTx(tx)
+		if err != nil {
+			writeSignerErr(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		raw, err := rlp.EncodeToBytes(signed)
+		if err != nil {
+			writeSignerErr(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeSignerRes(w, &signerTxMsg{Tx: raw})
+	})
+	mux.HandleFunc(signerSignHashUrl, func(w http.ResponseWriter, r *http.Request) {
+		var req signerHashReq
+		if !readSignerReq(w, r, &req) {
+			return
+		}
+
+		if len(req.Hash) != common.HashLength {
+			writeSignerErr(w, http.StatusBadRequest, "hash must be 32 bytes")
+			return
+		}
+
+		sig, err := signer.SignHash(req.Hash)
+		if err != nil {
+			writeSignerErr(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeSignerRes(w, &signerHashRes{Signature: sig})
+	})
+
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 常量时间比较，避免通过响应时间猜测 token
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeSignerErr(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func readSignerReq(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if r.Method != http.MethodPost {
+		writeSignerErr(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeSignerErr(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+func writeSignerRes(w http.ResponseWriter, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func writeSignerErr(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&signerErrRes{Error: msg})
+}
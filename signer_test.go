@@ -0,0 +1,51 @@
+package sdk
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/eth/types"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key.SetChainID(big.NewInt(7))
+
+	server := httptest.NewServer(NewSignerHandler(key, "secret"))
+	defer server.Close()
+
+	if _, err := NewRemoteSigner(server.URL, WithSignerToken("wrong")); err == nil {
+		t.Fatalf("NewRemoteSigner: expected unauthorized error")
+	}
+
+	rs, err := NewRemoteSigner(server.URL, WithSignerToken("secret"))
+	if err != nil {
+		t.Fatalf("NewRemoteSigner: %v", err)
+	}
+	if rs.GetAddress() != key.GetAddress() {
+		t.Fatalf("address mismatch: have %s, want %s", rs.GetAddress().String(), key.GetStringAddress())
+	}
+
+	tx := types.NewTransaction(3, common.Address{0x02}, big.NewInt(100), 21000, big.NewInt(1), []byte{0x01})
+	signed, err := rs.SignTx(tx)
+	if err != nil {
+		t.Fatalf("SignTx: %v", err)
+	}
+	if signed.ChainId().Cmp(big.NewInt(7)) != 0 || signed.Nonce() != 3 {
+		t.Fatalf("unexpected signed tx: chain id %v, nonce %d", signed.ChainId(), signed.Nonce())
+	}
+
+	hash := TextHash([]byte("remote"))
+	sig, err := rs.SignHash(hash)
+	if err != nil {
+		t.Fatalf("SignHash: %v", err)
+	}
+	if addr, err := RecoverHashAddress(hash, sig); err != nil || addr != key.GetAddress() {
+		t.Fatalf("RecoverHashAddress: %s, %v", addr.String(), err)
+	}
+}
@@ -0,0 +1,178 @@
+package sdk
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/pkg/errors"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/hexutil"
+	"github.com/bolaxy/eth/types"
+	"github.com/bolaxy/rlp"
+)
+
+var (
+	ErrNonceRequired = errors.New("nonce is required")
+	ErrGasRequired   = errors.New("gas is required")
+)
+
+// TxBuilder 交易构造器，链式设置交易字段，签名后输出 rpc.Client.Transact 需要的数据
+//
+//	tx, raw, err := sdk.NewTxBuilder().To(to).Value(value).Gas(21000).Nonce(nonce).Sign(key)
+//	res, err := client.Transact(raw)
+type TxBuilder struct {
+	to          *common.Address
+	value       *big.Int
+	data        []byte
+	gas         uint64
+	gasPrice    *big.Int
+	nonce       *uint64
+	chainID     *big.Int
+	fromChainID string
+	toChainID   string
+	fromTxHash  *common.Hash
+	txType      types.TransactionType
+}
+
+// NewTxBuilder 创建交易构造器，默认为普通交易 types.Tx
+func NewTxBuilder() *TxBuilder {
+	return &TxBuilder{txType: types.Tx}
+}
+
+// To 接收地址，不设置时为合约创建交易
+func (b *TxBuilder) To(to common.Address) *TxBuilder {
+	b.to = &to
+	return b
+}
+
+// Value 转账金额
+func (b *TxBuilder) Value(value *big.Int) *TxBuilder {
+	b.value = value
+	return b
+}
+
+// Data 交易数据，合约调用的 input 或合约创建的字节码
+func (b *TxBuilder) Data(data []byte) *TxBuilder {
+	b.data = data
+	return b
+}
+
+// Gas gas 上限
+func (b *TxBuilder) Gas(gas uint64) *TxBuilder {
+	b.gas = gas
+	return b
+}
+
+// GasPrice gas 价格
+func (b *TxBuilder) GasPrice(gasPrice *big.Int) *TxBuilder {
+	b.gasPrice = gasPrice
+	return b
+}
+
+// Nonce 账户 nonce
+func (b *TxBuilder) Nonce(nonce uint64) *TxBuilder {
+	b.nonce = &nonce
+	return b
+}
+
+// ChainID 期望的链ID，签名后校验签名者使用的链ID是否一致
+func (b *TxBuilder) ChainID(chainID *big.Int) *TxBuilder {
+	b.chainID = chainID
+	return b
+}
+
+// FromChainID 跨链交易的源链ID
+func (b *TxBuilder) FromChainID(fromChainID string) *TxBuilder {
+	b.fromChainID = fromChainID
+	return b
+}
+
+// ToChainID 跨链交易的目标链ID
+func (b *TxBuilder) ToChainID(toChainID string) *TxBuilder {
+	b.toChainID = toChainID
+	return b
+}
+
+// FromTxHash 跨链交易在源链上的交易哈希
+func (b *TxBuilder) FromTxHash(hash common.Hash) *TxBuilder {
+	b.fromTxHash = &hash
+	return b
+}
+
+// TxType 交易类型
+func (b *TxBuilder) TxType(txType types.TransactionType) *TxBuilder {
+	b.txType = txType
+	return b
+}
+
+// HasNonce 是否已设置 nonce
+func (b *TxBuilder) HasNonce() bool {
+	return b.nonce != nil
+}
+
+// Build 构造未签名的交易
+func (b *TxBuilder) Build() (*types.Transaction, error) {
+	if b.nonce == nil {
+		return nil, ErrNonceRequired
+	}
+	if b.gas == 0 {
+		return nil, ErrGasRequired
+	}
+
+	if b.to == nil {
+		if b.fromChainID != "" || b.toChainID != "" || b.fromTxHash != nil || b.txType != types.Tx {
+			return nil, errors.New("contract creation does not support cross chain fields")
+		}
+		return types.NewContractCreation(*b.nonce, b.value, b.gas, b.gasPrice, b.data), nil
+	}
+
+	return types.NewOrgTransaction(*b.nonce, *b.to, b.value, b.gas, b.gasPrice, b.data,
+		b.fromTxHash, b.fromChainID, b.toChainID, b.txType), nil
+}
+
+// Sign 构造交易并签名，返回签名后的交易和 rpc.Client.Transact 需要的 hex 编码 RLP 数据
+func (b *TxBuilder) Sign(signer Signer) (*types.Transaction, string, error) {
+	tx, err := b.Build()
+	if err != nil {
+		return nil, "", err
+	}
+
+	signed, err := signer.SignTx(tx)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "sign tx")
+	}
+
+	if b.chainID != nil && signed.ChainId().Cmp(b.chainID) != 0 {
+		return nil, "", fmt.Errorf("%w: signed with chain id %v, want %v", ErrChainIDMismatch, signed.ChainId(), b.chainID)
+	}
+
+	raw, err := EncodeTx(signed)
+	if err != nil {
+		return nil, "", err
+	}
+	return signed, raw, nil
+}
+
+// EncodeTx 将已签名的交易编码为 rpc.Client.Transact 需要的 hex 编码 RLP 数据
+func EncodeTx(tx *types.Transaction) (string, error) {
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return "", errors.Wrap(err, "rlp encode tx")
+	}
+	return hexutil.Encode(raw), nil
+}
+
+// DecodeTx 解码 hex 编码的 RLP 交易数据，EncodeTx 的逆操作
+func DecodeTx(raw string) (*types.Transaction, error) {
+	payload, err := hexutil.Decode(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "hex decode tx")
+	}
+
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(payload, tx); err != nil {
+		return nil, errors.Wrap(err, "rlp decode tx")
+	}
+	return tx, nil
+}
@@ -0,0 +1,45 @@
+package sdk
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/eth/types"
+)
+
+func TestTxBuilder(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	to := common.HexToAddress("0x599D7ABDB0A289F85aACA706b55D1b96cc07f348")
+	builder := NewTxBuilder().To(to).Value(big.NewInt(1000)).Gas(21000).GasPrice(big.NewInt(1)).ToChainID("2")
+	if _, _, err := builder.Sign(key); err != ErrNonceRequired {
+		t.Fatalf("Sign: have %v, want %v", err, ErrNonceRequired)
+	}
+
+	signed, raw, err := builder.Nonce(9).Sign(key)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	tx, err := DecodeTx(raw)
+	if err != nil {
+		t.Fatalf("DecodeTx: %v", err)
+	}
+	if tx.Hash() != signed.Hash() || tx.Nonce() != 9 || *tx.To() != to || tx.ToChainid() != "2" {
+		t.Fatalf("unexpected decoded tx: %s", tx.Hash().String())
+	}
+
+	from, err := types.Sender(types.NewEIP155Signer(key.ChainID()), tx)
+	if err != nil || from != key.GetAddress() {
+		t.Fatalf("Sender: %s, %v", from.String(), err)
+	}
+
+	if _, _, err := builder.ChainID(big.NewInt(99)).Sign(key); !errors.Is(err, ErrChainIDMismatch) {
+		t.Fatalf("Sign: have %v, want %v", err, ErrChainIDMismatch)
+	}
+}
@@ -0,0 +1,436 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/hexutil"
+	"github.com/bolaxy/common/math"
+	"github.com/bolaxy/crypto"
+)
+
+const domainType = "EIP712Domain"
+
+var typedArrayRegexp = regexp.MustCompile(`^(.+)\[(\d*)\]$`)
+
+// TypedDataField EIP-712 结构体字段
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedDataTypes EIP-712 类型定义，结构体名 -> 字段列表
+type TypedDataTypes map[string][]TypedDataField
+
+// TypedDataDomain EIP-712 域，为空的字段不参与编码
+type TypedDataDomain struct {
+	Name              string          `json:"name,omitempty"`
+	Version           string          `json:"version,omitempty"`
+	ChainId           *big.Int        `json:"chainId,omitempty"`
+	VerifyingContract *common.Address `json:"verifyingContract,omitempty"`
+	Salt              *common.Hash    `json:"salt,omitempty"`
+}
+
+// TypedData EIP-712 结构化数据
+type TypedData struct {
+	Types       TypedDataTypes         `json:"types"`
+	PrimaryType string                 `json:"primaryType"`
+	Domain      TypedDataDomain        `json:"domain"`
+	Message     map[string]interface{} `json:"message"`
+}
+
+// Hash 计算待签名的哈希 keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+func (td *TypedData) Hash() ([]byte, error) {
+	separator, err := td.DomainSeparator()
+	if err != nil {
+		return nil, errors.Wrap(err, "domain separator")
+	}
+
+	msgHash, err := td.HashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return nil, errors.Wrap(err, "hash message")
+	}
+
+	return crypto.Keccak256([]byte{0x19, 0x01}, separator, msgHash), nil
+}
+
+// DomainSeparator 计算域分隔符 hashStruct(eip712Domain)
+func (td *TypedData) DomainSeparator() ([]byte, error) {
+	return td.HashStruct(domainType, td.Domain.Map())
+}
+
+// HashStruct 计算结构体哈希 keccak256(typeHash ‖ encodeData(s))
+func (td *TypedData) HashStruct(primaryType string, data map[string]interface{}) ([]byte, error) {
+	encoded, err := td.EncodeData(primaryType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.Keccak256(encoded), nil
+}
+
+// TypeHash 计算类型哈希 keccak256(encodeType(primaryType))
+func (td *TypedData) TypeHash(primaryType string) ([]byte, error) {
+	encoded, err := td.EncodeType(primaryType)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.Keccak256([]byte(encoded)), nil
+}
+
+// EncodeType 编码类型，例如 Mail(Person from,Person to,string contents)Person(string name,address wallet)
+// 引用的结构体按名称排序后追加在主类型之后
+func (td *TypedData) EncodeType(primaryType string) (string, error) {
+	types := td.types()
+	if _, ok := types[primaryType]; !ok {
+		return "", fmt.Errorf("unknown type %s", primaryType)
+	}
+
+	deps := td.dependencies(primaryType, nil)
+	sort.Strings(deps[1:])
+
+	var buf bytes.Buffer
+	for _, dep := range deps {
+		buf.WriteString(dep)
+		buf.WriteString("(")
+		for i, field := range types[dep] {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(field.Type)
+			buf.WriteString(" ")
+			buf.WriteString(field.Name)
+		}
+		buf.WriteString(")")
+	}
+	return buf.String(), nil
+}
+
+// EncodeData 编码结构体数据 typeHash ‖ encodeValue(field1) ‖ ... ‖ encodeValue(fieldN)
+func (td *TypedData) EncodeData(primaryType string, data map[string]interface{}) ([]byte, error) {
+	fields, ok := td.types()[primaryType]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", primaryType)
+	}
+
+	if len(data) > len(fields) {
+		return nil, fmt.Errorf("%s: too many fields, have %d, want %d", primaryType, len(data), len(fields))
+	}
+
+	typeHash, err := td.TypeHash(primaryType)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(typeHash)
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s: missing field %s", primaryType, field.Name)
+		}
+
+		encoded, err := td.encodeValue(field.Type, value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s.%s", primaryType, field.Name)
+		}
+		buf.Write(encoded)
+	}
+	return buf.Bytes(), nil
+}
+
+// Map 返回用于编码的域数据
+func (domain *TypedDataDomain) Map() map[string]interface{} {
+	data := make(map[string]interface{})
+	if domain.Name != "" {
+		data["name"] = domain.Name
+	}
+	if domain.Version != "" {
+		data["version"] = domain.Version
+	}
+	if domain.ChainId != nil {
+		data["chainId"] = domain.ChainId
+	}
+	if domain.VerifyingContract != nil {
+		data["verifyingContract"] = *domain.VerifyingContract
+	}
+	if domain.Salt != nil {
+		data["salt"] = *domain.Salt
+	}
+	return data
+}
+
+// fields 返回域中非空字段的类型定义
+func (domain *TypedDataDomain) fields() []TypedDataField {
+	fields := make([]TypedDataField, 0, 5)
+	if domain.Name != "" {
+		fields = append(fields, TypedDataField{Name: "name", Type: "string"})
+	}
+	if domain.Version != "" {
+		fields = append(fields, TypedDataField{Name: "version", Type: "string"})
+	}
+	if domain.ChainId != nil {
+		fields = append(fields, TypedDataField{Name: "chainId", Type: "uint256"})
+	}
+	if domain.VerifyingContract != nil {
+		fields = append(fields, TypedDataField{Name: "verifyingContract", Type: "address"})
+	}
+	if domain.Salt != nil {
+		fields = append(fields, TypedDataField{Name: "salt", Type: "bytes32"})
+	}
+	return fields
+}
+
+// domainDeclares 检查显式定义的 EIP712Domain 是否包含字段 name
+func (td *TypedData) domainDeclares(name string) bool {
+	for _, field := range td.Types[domainType] {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// types 返回类型定义，未定义 EIP712Domain 时由 Domain 推导
+func (td *TypedData) types() TypedDataTypes {
+	if _, ok := td.Types[domainType]; ok {
+		return td.Types
+	}
+
+	types := make(TypedDataTypes, len(td.Types)+1)
+	for name, fields := range td.Types {
+		types[name] = fields
+	}
+	types[domainType] = td.Domain.fields()
+	return types
+}
+
+// dependencies 返回 primaryType 及其引用的所有结构体类型，primaryType 在首位
+func (td *TypedData) dependencies(primaryType string, found []string) []string {
+	primaryType = strings.TrimSuffix(typedArrayRegexp.ReplaceAllString(primaryType, "$1"), "[]")
+	for _, dep := range found {
+		if dep == primaryType {
+			return found
+		}
+	}
+
+	fields, ok := td.types()[primaryType]
+	if !ok {
+		return found
+	}
+
+	found = append(found, primaryType)
+	for _, field := range fields {
+		found = td.dependencies(field.Type, found)
+	}
+	return found
+}
+
+func (td *TypedData) encodeValue(typ string, value interface{}) ([]byte, error) {
+	if match := typedArrayRegexp.FindStringSubmatch(typ); match != nil {
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return nil, fmt.Errorf("invalid array value %v for type %s", value, typ)
+		}
+		if match[2] != "" {
+			size, _ := strconv.Atoi(match[2])
+			if rv.Len() != size {
+				return nil, fmt.Errorf("array length %d, want %d", rv.Len(), size)
+			}
+		}
+
+		var buf bytes.Buffer
+		for i := 0; i < rv.Len(); i++ {
+			encoded, err := td.encodeValue(match[1], rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(encoded)
+		}
+		return crypto.Keccak256(buf.Bytes()), nil
+	}
+
+	if _, ok := td.types()[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid struct value %v for type %s", value, typ)
+		}
+		return td.HashStruct(typ, data)
+	}
+
+	return encodeAtomicValue(typ, value)
+}
+
+func encodeAtomicValue(typ string, value interface{}) ([]byte, error) {
+	switch {
+	case typ == "address":
+		raw, err := parseTypedBytes(value)
+		if err != nil || len(raw) != common.AddressLength {
+			return nil, fmt.Errorf("invalid address value %v", value)
+		}
+		return common.LeftPadBytes(raw, 32), nil
+
+	case typ == "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid bool value %v", value)
+		}
+		if b {
+			return u256Bytes(big.NewInt(1)), nil
+		}
+		return u256Bytes(big.NewInt(0)), nil
+
+	case typ == "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid string value %v", value)
+		}
+		return crypto.Keccak256([]byte(s)), nil
+
+	case typ == "bytes":
+		raw, err := parseTypedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(raw), nil
+
+	case strings.HasPrefix(typ, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(typ, "bytes"))
+		if err != nil || size < 1 || size > 32 {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		raw, err := parseTypedBytes(value)
+		if err != nil || len(raw) != size {
+			return nil, fmt.Errorf("invalid %s value %v", typ, value)
+		}
+		return common.RightPadBytes(raw, 32), nil
+
+	case strings.HasPrefix(typ, "uint") || strings.HasPrefix(typ, "int"):
+		signed := strings.HasPrefix(typ, "int")
+		bits, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(typ, "u"), "int"))
+		if err != nil || bits < 8 || bits > 256 || bits%8 != 0 {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		n, err := parseTypedInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		if !integerInRange(n, bits, signed) {
+			return nil, fmt.Errorf("%s value %v out of range", typ, value)
+		}
+		return u256Bytes(new(big.Int).Set(n)), nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
+
+// integerInRange 检查 n 是否在 bits 位有符号或无符号整数的范围内
+func integerInRange(n *big.Int, bits int, signed bool) bool {
+	if !signed {
+		return n.Sign() >= 0 && n.BitLen() <= bits
+	}
+	// 有符号整数范围为 [-2^(bits-1), 2^(bits-1))
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+	return n.Cmp(limit) < 0 && n.Cmp(new(big.Int).Neg(limit)) >= 0
+}
+
+// u256Bytes 将整数转换为32字节补码表示，会修改 n
+func u256Bytes(n *big.Int) []byte {
+	return math.PaddedBigBytes(math.U256(n), 32)
+}
+
+func parseTypedBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return hexutil.Decode(v)
+	case common.Address:
+		return v.Bytes(), nil
+	case *common.Address:
+		return v.Bytes(), nil
+	case common.Hash:
+		return v.Bytes(), nil
+	case *common.Hash:
+		return v.Bytes(), nil
+	}
+	return nil, fmt.Errorf("invalid bytes value %v", value)
+}
+
+func parseTypedInteger(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v, nil
+	case big.Int:
+		return &v, nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("invalid integer value %v", v)
+		}
+		return big.NewInt(int64(v)), nil
+	case json.Number:
+		return parseTypedInteger(string(v))
+	case string:
+		n, ok := math.ParseBig256(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer value %s", v)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("invalid integer value %v", value)
+}
+
+// SignTypedData 对 EIP-712 结构化数据签名，V 为 27 或 28，可直接用于合约 ecrecover。
+// 为防止签名在其他链上重放，Domain.ChainId 未设置或显式定义的 EIP712Domain 未声明 chainId 时返回错误，
+// Domain.ChainId 与密钥链ID不一致时返回 ErrChainIDMismatch
+func (k *Key) SignTypedData(td *TypedData) ([]byte, error) {
+	if td.Domain.ChainId == nil {
+		return nil, fmt.Errorf("domain chain id is not set, want %v", k.ChainID())
+	}
+	if _, ok := td.Types[domainType]; ok && !td.domainDeclares("chainId") {
+		return nil, fmt.Errorf("%s does not declare chainId", domainType)
+	}
+	if td.Domain.ChainId != nil && td.Domain.ChainId.Cmp(k.ChainID()) != 0 {
+		return nil, fmt.Errorf("%w: domain chain id %v, want %v", ErrChainIDMismatch, td.Domain.ChainId, k.ChainID())
+	}
+
+	hash, err := td.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := k.SignHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	sig[recoveryIDOffset] += 27
+	return sig, nil
+}
+
+// RecoverTypedDataSigner 由 EIP-712 结构化数据和签名恢复签名者地址
+func RecoverTypedDataSigner(td *TypedData, sig []byte) (common.Address, error) {
+	hash, err := td.Hash()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return RecoverHashAddress(hash, sig)
+}
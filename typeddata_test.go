@@ -0,0 +1,214 @@
+package sdk
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/hexutil"
+	"github.com/bolaxy/crypto"
+)
+
+// mail example from https://eips.ethereum.org/EIPS/eip-712
+const mailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestTypedDataHash(t *testing.T) {
+	var td TypedData
+	if err := json.Unmarshal([]byte(mailTypedData), &td); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	encoded, err := td.EncodeType("Mail")
+	if err != nil {
+		t.Fatalf("EncodeType: %v", err)
+	}
+	if want := "Mail(Person from,Person to,string contents)Person(string name,address wallet)"; encoded != want {
+		t.Fatalf("EncodeType: have %s, want %s", encoded, want)
+	}
+
+	separator, err := td.DomainSeparator()
+	if err != nil {
+		t.Fatalf("DomainSeparator: %v", err)
+	}
+	if have, want := hexutil.Encode(separator), "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"; have != want {
+		t.Fatalf("DomainSeparator: have %s, want %s", have, want)
+	}
+
+	hash, err := td.Hash()
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if have, want := hexutil.Encode(hash), "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"; have != want {
+		t.Fatalf("Hash: have %s, want %s", have, want)
+	}
+
+	// the domain type can also be derived from the domain itself
+	delete(td.Types, domainType)
+	derived, err := td.Hash()
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if hexutil.Encode(derived) != hexutil.Encode(hash) {
+		t.Fatalf("Hash mismatch with derived domain type")
+	}
+}
+
+func TestSignTypedData(t *testing.T) {
+	var td TypedData
+	if err := json.Unmarshal([]byte(mailTypedData), &td); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	key, err := RecoverKey(crypto.Keccak256([]byte("cow")))
+	if err != nil {
+		t.Fatalf("RecoverKey: %v", err)
+	}
+
+	sig, err := key.SignTypedData(&td)
+	if err != nil {
+		t.Fatalf("SignTypedData: %v", err)
+	}
+
+	want := "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c"
+	if hexutil.Encode(sig) != want {
+		t.Fatalf("signature: have %s, want %s", hexutil.Encode(sig), want)
+	}
+
+	signer, err := RecoverTypedDataSigner(&td, sig)
+	if err != nil {
+		t.Fatalf("RecoverTypedDataSigner: %v", err)
+	}
+	if signer != common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826") {
+		t.Fatalf("signer mismatch: %s", signer.String())
+	}
+
+	key.SetChainID(big.NewInt(2))
+	if _, err := key.SignTypedData(&td); err == nil {
+		t.Fatalf("SignTypedData: expected chain id mismatch")
+	}
+}
+
+func TestTypedDataUnknownType(t *testing.T) {
+	var td TypedData
+	if err := json.Unmarshal([]byte(mailTypedData), &td); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	td.PrimaryType = "Letter"
+
+	if _, err := td.EncodeType("Letter"); err == nil {
+		t.Fatalf("EncodeType: expected unknown type error")
+	}
+	if _, err := td.TypeHash("Letter"); err == nil {
+		t.Fatalf("TypeHash: expected unknown type error")
+	}
+	if _, err := td.Hash(); err == nil {
+		t.Fatalf("Hash: expected unknown type error")
+	}
+}
+
+func TestSignTypedData_Domain(t *testing.T) {
+	key, err := RecoverKey(crypto.Keccak256([]byte("cow")))
+	if err != nil {
+		t.Fatalf("RecoverKey: %v", err)
+	}
+	key.SetChainID(big.NewInt(1))
+
+	// the signature could be replayed on any chain without a chain id
+	var td TypedData
+	if err := json.Unmarshal([]byte(mailTypedData), &td); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	td.Domain.ChainId = nil
+	if _, err := key.SignTypedData(&td); err == nil {
+		t.Fatalf("SignTypedData: expected unset chain id error")
+	}
+	delete(td.Types, domainType)
+	if _, err := key.SignTypedData(&td); err == nil {
+		t.Fatalf("SignTypedData with derived domain type: expected unset chain id error")
+	}
+
+	// the domain type is derived from the domain, which has the chainId
+	td.Domain.ChainId = big.NewInt(1)
+	sig, err := key.SignTypedData(&td)
+	if err != nil {
+		t.Fatalf("SignTypedData: %v", err)
+	}
+	signer, err := RecoverTypedDataSigner(&td, sig)
+	if err != nil || signer != key.GetAddress() {
+		t.Fatalf("RecoverTypedDataSigner: have %s, %v, want %s", signer.String(), err, key.GetStringAddress())
+	}
+
+	// the domain type does not declare chainId, so it is not signed
+	td = TypedData{}
+	if err := json.Unmarshal([]byte(mailTypedData), &td); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var fields []TypedDataField
+	for _, field := range td.Types[domainType] {
+		if field.Name != "chainId" {
+			fields = append(fields, field)
+		}
+	}
+	td.Types[domainType] = fields
+	if _, err := key.SignTypedData(&td); err == nil {
+		t.Fatalf("SignTypedData: expected undeclared chain id error")
+	}
+}
+
+func TestTypedDataValueRange(t *testing.T) {
+	for _, tc := range []struct {
+		typ   string
+		value interface{}
+		ok    bool
+	}{
+		{"uint8", float64(255), true},
+		{"uint8", float64(256), false},
+		{"uint8", float64(-1), false},
+		{"int8", float64(127), true},
+		{"int8", float64(-128), true},
+		{"int8", float64(128), false},
+		{"int8", float64(-129), false},
+		{"uint256", "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", true},
+		{"int256", "0x8000000000000000000000000000000000000000000000000000000000000000", false},
+		{"uint7", float64(1), false},
+		{"bytes4", "0x12345678", true},
+		{"bytes4", "0x123456", false},
+		{"bytes4", "0x1234567890", false},
+	} {
+		_, err := encodeAtomicValue(tc.typ, tc.value)
+		if (err == nil) != tc.ok {
+			t.Errorf("encode %s %v: have %v, want ok %v", tc.typ, tc.value, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/eth/types"
+)
+
+var (
+	ErrNoAccount     = errors.New("no such account")
+	ErrAccountExists = errors.New("account already exists")
+	ErrLocked        = errors.New("account is locked")
+)
+
+// Wallet 管理一个目录下的多个加密 keystore 文件。
+// 账户需先用口令解锁才能签名，解锁可设置有效期。
+type Wallet struct {
+	dir     string
+	chainID *big.Int
+
+	mu       sync.Mutex
+	unlocked map[common.Address]*unlocked
+}
+
+type unlocked struct {
+	key   *Key
+	timer *time.Timer
+}
+
+// WalletOpt options of Wallet
+type WalletOpt func(w *Wallet)
+
+// WithWalletChainID 设置账户签名使用的链ID，未设置时使用 common.ChainID
+func WithWalletChainID(chainID *big.Int) WalletOpt {
+	return func(w *Wallet) {
+		w.chainID = chainID
+	}
+}
+
+// NewWallet 打开（必要时创建）keystore 目录
+func NewWallet(dir string, opts ...WalletOpt) (*Wallet, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "create keystore dir")
+	}
+
+	w := &Wallet{
+		dir:      dir,
+		unlocked: make(map[common.Address]*unlocked),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w, nil
+}
+
+// ChainID 返回账户签名使用的链ID
+func (w *Wallet) ChainID() *big.Int {
+	if w.chainID == nil {
+		return common.ChainID
+	}
+	return w.chainID
+}
+
+// Accounts 返回目录下所有账户地址，按文件名排序
+func (w *Wallet) Accounts() ([]common.Address, error) {
+	files, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	addrs := make([]common.Address, 0, len(names))
+	for _, name := range names {
+		addrs = append(addrs, files[name])
+	}
+	return addrs, nil
+}
+
+// HasAccount 检查目录下是否存在该地址的 keystore 文件
+func (w *Wallet) HasAccount(addr common.Address) bool {
+	_, err := w.find(addr)
+	return err == nil
+}
+
+// NewAccount 生成新密钥，用口令加密后写入目录
+func (w *Wallet) NewAccount(passphrase string) (common.Address, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return w.ImportKey(key, passphrase)
+}
+
+// ImportKey 用口令加密已有密钥并写入目录
+func (w *Wallet) ImportKey(key *Key, passphrase string) (common.Address, error) {
+	if w.HasAccount(key.GetAddress()) {
+		return common.Address{}, ErrAccountExists
+	}
+
+	keyjson, err := key.EncryptJSON(passphrase)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	if err := w.write(key.GetAddress(), keyjson); err != nil {
+		return common.Address{}, err
+	}
+	return key.GetAddress(), nil
+}
+
+// ImportJSON 导入 keystore 文件内容，用 newPassphrase 重新加密后写入目录
+func (w *Wallet) ImportJSON(keyjson []byte, passphrase, newPassphrase string) (common.Address, error) {
+	key, err := DecryptKeyJSON(keyjson, passphrase)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return w.ImportKey(key, newPassphrase)
+}
+
+// Export 导出账户的 keystore 文件内容，用 newPassphrase 重新加密
+func (w *Wallet) Export(addr common.Address, passphrase, newPassphrase string) ([]byte, error) {
+	key, err := w.getKey(addr, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.EncryptJSON(newPassphrase)
+}
+
+// Delete 校验口令后删除账户的 keystore 文件，并锁定该账户
+func (w *Wallet) Delete(addr common.Address, passphrase string) error {
+	if _, err := w.getKey(addr, passphrase); err != nil {
+		return err
+	}
+
+	path, err := w.find(addr)
+	if err != nil {
+		return err
+	}
+
+	w.Lock(addr)
+	return os.Remove(path)
+}
+
+// Unlock 用口令解锁账户。timeout 为 0 时一直保持解锁，直到调用 Lock。
+func (w *Wallet) Unlock(addr common.Address, passphrase string, timeout time.Duration) error {
+	key, err := w.getKey(addr, passphrase)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if u, ok := w.unlocked[addr]; ok && u.timer != nil {
+		u.timer.Stop()
+	}
+
+	u := &unlocked{key: key}
+	if timeout > 0 {
+		u.timer = time.AfterFunc(timeout, func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			if w.unlocked[addr] == u {
+				delete(w.unlocked, addr)
+			}
+		})
+	}
+	w.unlocked[addr] = u
+	return nil
+}
+
+// Lock 锁定账户，清除内存中的密钥
+func (w *Wallet) Lock(addr common.Address) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if u, ok := w.unlocked[addr]; ok {
+		if u.timer != nil {
+			u.timer.Stop()
+		}
+		delete(w.unlocked, addr)
+	}
+}
+
+// IsUnlocked 检查账户是否已解锁
+func (w *Wallet) IsUnlocked(addr common.Address) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, ok := w.unlocked[addr]
+	return ok
+}
+
+// SignTx 用已解锁账户的密钥和钱包的链ID对交易签名
+func (w *Wallet) SignTx(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	w.mu.Lock()
+	u, ok := w.unlocked[addr]
+	w.mu.Unlock()
+	if !ok {
+		return nil, ErrLocked
+	}
+
+	return u.key.SignTx(tx)
+}
+
+func (w *Wallet) getKey(addr common.Address, passphrase string) (*Key, error) {
+	path, err := w.find(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	keyjson, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read key file")
+	}
+
+	key, err := DecryptKeyJSON(keyjson, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.GetAddress() != addr {
+		return nil, fmt.Errorf("key content mismatch: have account %x, want %x", key.GetAddress(), addr)
+	}
+	key.SetChainID(w.chainID)
+	return key, nil
+}
+
+func (w *Wallet) find(addr common.Address) (string, error) {
+	files, err := w.scan()
+	if err != nil {
+		return "", err
+	}
+
+	for name, a := range files {
+		if a == addr {
+			return filepath.Join(w.dir, name), nil
+		}
+	}
+	return "", ErrNoAccount
+}
+
+// scan 读取目录下所有 keystore 文件，返回文件名到地址的映射
+func (w *Wallet) scan() (map[string]common.Address, error) {
+	fis, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read keystore dir")
+	}
+
+	files := make(map[string]common.Address, len(fis))
+	for _, fi := range fis {
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") || strings.HasSuffix(fi.Name(), "~") {
+			continue
+		}
+
+		raw, err := ioutil.ReadFile(filepath.Join(w.dir, fi.Name()))
+		if err != nil {
+			continue
+		}
+
+		var content struct {
+			Address string `json:"address"`
+		}
+		if err := json.Unmarshal(raw, &content); err != nil || !common.IsHexAddress(content.Address) {
+			continue
+		}
+		files[fi.Name()] = common.HexToAddress(content.Address)
+	}
+	return files, nil
+}
+
+// write 以 UTC--<时间>--<地址> 命名写入 keystore 文件，先写临时文件再重命名
+func (w *Wallet) write(addr common.Address, keyjson []byte) error {
+	ts := time.Now().UTC().Format("2006-01-02T15-04-05.000000000Z")
+	name := fmt.Sprintf("UTC--%s--%s", ts, common.Bytes2Hex(addr.Bytes()))
+
+	f, err := ioutil.TempFile(w.dir, "."+name+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "create key file")
+	}
+	if _, err := f.Write(keyjson); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return errors.Wrap(err, "write key file")
+	}
+	f.Close()
+
+	return os.Rename(f.Name(), filepath.Join(w.dir, name))
+}
@@ -0,0 +1,99 @@
+package sdk
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/eth/types"
+)
+
+func TestWallet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewWallet(dir)
+	if err != nil {
+		t.Fatalf("NewWallet: %v", err)
+	}
+
+	addr, err := w.NewAccount("foo")
+	if err != nil {
+		t.Fatalf("NewAccount: %v", err)
+	}
+
+	accs, err := w.Accounts()
+	if err != nil {
+		t.Fatalf("Accounts: %v", err)
+	}
+	if len(accs) != 1 || accs[0] != addr {
+		t.Fatalf("unexpected accounts: %v", accs)
+	}
+
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	if _, err := w.SignTx(addr, tx); err != ErrLocked {
+		t.Fatalf("SignTx: have %v, want %v", err, ErrLocked)
+	}
+
+	if err := w.Unlock(addr, "bar", 0); err == nil {
+		t.Fatalf("Unlock: expected error with wrong passphrase")
+	}
+	if err := w.Unlock(addr, "foo", 100*time.Millisecond); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if _, err := w.SignTx(addr, tx); err != nil {
+		t.Fatalf("SignTx: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if w.IsUnlocked(addr) {
+		t.Fatalf("account still unlocked after timeout")
+	}
+
+	if err := w.Delete(addr, "foo"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if w.HasAccount(addr) {
+		t.Fatalf("account still exists after delete")
+	}
+}
+
+func TestWallet_ChainID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chainID := big.NewInt(99)
+	w, err := NewWallet(dir, WithWalletChainID(chainID))
+	if err != nil {
+		t.Fatalf("NewWallet: %v", err)
+	}
+	addr, err := w.NewAccount("foo")
+	if err != nil {
+		t.Fatalf("NewAccount: %v", err)
+	}
+	if err := w.Unlock(addr, "foo", 0); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := w.SignTx(addr, tx)
+	if err != nil {
+		t.Fatalf("SignTx: %v", err)
+	}
+	if signed.ChainId().Cmp(chainID) != 0 {
+		t.Fatalf("chain id: have %v, want %v", signed.ChainId(), chainID)
+	}
+	from, err := types.Sender(types.NewEIP155Signer(chainID), signed)
+	if err != nil || from != addr {
+		t.Fatalf("sender: have %s, %v, want %s", from.String(), err, addr.String())
+	}
+}